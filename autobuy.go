@@ -6,61 +6,417 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/adyzng/go-jd/core"
+	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
 	clog "gopkg.in/clog.v1"
 )
 
-func init() {
+// initLog write the logs to stderr if stderr is set, stdout is left to
+// the json output
+//
+func initLog(level clog.LEVEL, stderr bool) {
+	if stderr {
+		color.Output = colorable.NewColorableStderr()
+	}
 	if err := clog.New(clog.CONSOLE, clog.ConsoleConfig{
-		Level:      clog.INFO,
+		Level:      level,
 		BufferSize: 100},
 	); err != nil {
-		fmt.Printf("init console log failed. error %+v.", err)
+		fmt.Fprintf(os.Stderr, "init console log failed. error %+v.\n", err)
 		os.Exit(1)
 	}
 }
 
 const (
 	AreaBeijing = "1_72_2799_0"
+
+	// maxGoodsCount is the max count of a goods in one order
+	maxGoodsCount = 200
 )
 
+// autobuy [command] [flags] [args]
+//
+// The command defaults to rush, so the flags of previous version without
+// command still work, e.g. `autobuy -goods 531065:2 -order`.
+//
+func main() {
+	args := os.Args[1:]
+	name := "rush"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		help(args)
+		return
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	os.Exit(cmd.execute(args))
+}
+
+// rush flags
 var (
-	area   = flag.String("area", AreaBeijing, "ship location string, default to Beijing")
-	period = flag.Int("period", 500, "the refresh period when out of stock, unit: ms.")
-	rush   = flag.Bool("rush", false, "continue to refresh when out of stock.")
-	order  = flag.Bool("order", false, "submit the order to JingDong when get the Goods.")
-	goods  = flag.String("goods", "", `the goods you want to by, find it from JD website. 
+	period   *int
+	rush     *bool
+	order    *bool
+	goods    *string
+	address  *string
+	limit    *string
+	profiles *string
+	workers  *int
+	policy   *string
+	fallback *time.Duration
+
+	pollName   *string
+	pollStart  *string
+	pollWindow *time.Duration
+	maxErrors  *int
+	deadline   *string
+	policyEnd  *string
+)
+
+func rushFlags(fs *flag.FlagSet) {
+	period = fs.Int("period", 500, "the refresh period when out of stock, unit: ms.")
+	rush = fs.Bool("rush", false, "continue to refresh when out of stock.")
+	pollName = fs.String("poll", "fixed", "the refresh strategy, fixed, jitter, backoff (on errors) or burst (around -start).")
+	pollStart = fs.String("start", "", "the start time of sale for -poll burst, e.g. 10:00:00 or 2017-11-11T00:00:00+08:00.")
+	pollWindow = fs.Duration("burst-window", 5*time.Second, "refresh faster within the window around -start.")
+	maxErrors = fs.Int("max-errors", 1, "give up the goods after the count of consecutive refresh errors.")
+	deadline = fs.String("deadline", "", "stop refreshing when passed, it or -policy-deadline is required by -rush of multiple goods, e.g. 10:30:00 or 2017-11-11T00:30:00+08:00.")
+	order = fs.Bool("order", false, "submit the order to JingDong when get the Goods.")
+	address = fs.String("address", "", "the ID or keyword of consignee address, the ship area follows the address.")
+	fallback = fs.Duration("fallback", 0, "buy the alternatives (ID|ID) when the goods is not in stock within the time, e.g. 30s. 0 buys the first in stock at once.")
+	policy = fs.String("order-policy", "any", "submit the order when any, all or at least N (min-N) goods are in cart.")
+	policyEnd = fs.String("policy-deadline", "", "check -order-policy by the time with the goods in cart, e.g. 10:05:00 or 2017-11-11T00:05:00+08:00.")
+	workers = fs.Int("workers", 0, "the max count of goods sending requests at the same time, 0 for all.")
+	limit = fs.String("limit", "fail", "what to do when the count exceeds the purchase limit, fail, clamp or split.")
+	profiles = fs.String("split-profiles", "", "the profiles separated by comma to split the goods with -limit split.")
+	goods = fs.String("goods", "", `the goods you want to by, find it from JD website.
 	Single Goods:
 	  2567304(:1)
 	Multiple Goods:
-	  2567304(:1),3133851(:2)`)
-)
+	  2567304(:1),3133851(:2)
+	Goods Link:
+	  https://item.jd.com/2567304.html(:1)
+	Alternatives (bought instead if out of stock, see -fallback):
+	  2567304(:1)|3133851(:1)`)
+}
 
-func main() {
-	flag.Parse()
-	defer clog.Shutdown()
-
-	gs := parseGoods(*goods)
-	clog.Trace("[Area: %+v, Goods: %qv, Period: %+v, Rush: %+v, Order: %+v]",
-		*area, gs, *period, *rush, *order)
-
-	jd := core.NewJingDong(core.JDConfig{
-		Period:     time.Millisecond * time.Duration(*period),
-		ShipArea:   *area,
-		AutoRush:   *rush,
-		AutoSubmit: *order,
+func runRush(e *env, args []string) error {
+	if job := e.job; job != nil {
+		if job.Period > 0 && !e.set["period"] {
+			*period = job.Period
+		}
+		if job.Rush != nil && !e.set["rush"] {
+			*rush = *job.Rush
+		}
+		if job.Order != nil && !e.set["order"] {
+			*order = *job.Order
+		}
+		if job.Address != "" && !e.set["address"] {
+			*address = job.Address
+		}
+		if job.OrderPolicy != "" && !e.set["order-policy"] {
+			*policy = job.OrderPolicy
+		}
+		if job.Poll.Strategy != "" && !e.set["poll"] {
+			*pollName = job.Poll.Strategy
+		}
+		if job.Poll.Start != "" && !e.set["start"] {
+			*pollStart = job.Poll.Start
+		}
+		if job.Poll.window > 0 && !e.set["burst-window"] {
+			*pollWindow = job.Poll.window
+		}
+		if job.Poll.MaxErrors > 0 && !e.set["max-errors"] {
+			*maxErrors = job.Poll.MaxErrors
+		}
+		if job.Workers > 0 && !e.set["workers"] {
+			*workers = job.Workers
+		}
+		if job.Limit.Policy != "" && !e.set["limit"] {
+			*limit = job.Limit.Policy
+		}
+		if len(job.Limit.Profiles) > 0 && !e.set["split-profiles"] {
+			*profiles = strings.Join(job.Limit.Profiles, ",")
+		}
+	}
+
+	limitPolicy, err := core.ParseLimitPolicy(*limit)
+	if err != nil {
+		return err
+	}
+	names := parseIDs([]string{*profiles})
+	if limitPolicy == core.LimitSplit && len(names) < 2 {
+		return fmt.Errorf("-limit split needs at least 2 profiles by -split-profiles")
+	}
+	orderPolicy, err := core.ParseOrderPolicy(*policy)
+	if err != nil {
+		return err
+	}
+	start, err := parseStart(*pollStart, time.Now())
+	if err != nil {
+		return fmt.Errorf("-start: %v", err)
+	}
+	if e.set["deadline"] {
+		if e.config.Deadline, err = parseStart(*deadline, time.Now()); err != nil {
+			return fmt.Errorf("-deadline: %v", err)
+		}
+	}
+	if e.set["policy-deadline"] {
+		if e.config.PolicyDeadline, err = parseStart(*policyEnd, time.Now()); err != nil {
+			return fmt.Errorf("-policy-deadline: %v", err)
+		}
+	}
+	poll, err := core.ParsePollStrategy(*pollName, time.Millisecond*time.Duration(*period), start, *pollWindow)
+	if err != nil {
+		return err
+	}
+
+	items, err := rushItems(e)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no goods specified, use -goods or -config")
+	}
+	if *rush && len(items) > 1 && e.config.Deadline.IsZero() && e.config.PolicyDeadline.IsZero() {
+		return fmt.Errorf("-rush of %d goods needs -deadline or -policy-deadline", len(items))
+	}
+
+	clog.Trace("[Area: %+v, Goods: %+v, Period: %+v, Rush: %+v, Order: %+v]",
+		e.opts.area, items, *period, *rush, *order)
+
+	e.config.Period = time.Millisecond * time.Duration(*period)
+	e.config.AutoRush = *rush
+	e.config.AutoSubmit = *order
+	e.config.Address = *address
+	e.config.LimitPolicy = limitPolicy
+	e.config.OrderPolicy = orderPolicy
+	e.config.Workers = *workers
+	e.config.Poll = poll
+	e.config.MaxPollErrors = *maxErrors
+
+	if pool := e.config.ProxyPool; pool != nil {
+		if n := pool.Check(5 * time.Second); n == 0 {
+			e.config.Logger.Warn("no healthy proxy in the pool, query the stock with -proxy")
+		} else {
+			e.config.Logger.Info("%d of %d proxies are healthy", n, len(pool.Stats()))
+		}
+	}
+
+	if limitPolicy == core.LimitSplit {
+		return splitRush(e, items, names)
+	}
+
+	jd := e.jingDong()
+	if err := jd.Login(); err != nil {
+		return err
+	}
+
+	report, err := jd.RushBuy(items)
+	printReport(e, "", report)
+	return err
+}
+
+// printReport print the rush report as table, or json in json output
+//
+func printReport(e *env, profile string, r *core.RushReport) {
+	if profile != "" && e.opts.output != OutputJSON {
+		fmt.Printf("%s: %s\n", e.msg(core.MsgLabelProfile), profile)
+	}
+
+	e.print(r, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, e.msg(core.MsgTableReport))
+		for _, it := range r.Items {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\t%s\t%s\t%s\t%s\n",
+				it.ID, it.Requested, it.Count, round(it.Detail), it.Polls,
+				round(it.StockWait), round(it.AddToCart), it.OrderID, errString(it.Err))
+		}
+
+		if o := r.Order; o != nil {
+			fmt.Fprintf(w, "\n")
+			if o.Preview != nil {
+				fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelGoods), o.Preview.WarePrice)
+				fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelShipping), o.Preview.ShipPrice)
+				fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelPayment), o.Preview.Payment)
+			}
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelPreview), round(o.PreviewTime))
+			if o.Submitted || o.SubmitTime > 0 {
+				fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelSubmit), round(o.SubmitTime))
+			}
+			if o.OrderID != "" {
+				fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelOrder), o.OrderID)
+			}
+			if o.Err != nil {
+				fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelError), o.Err)
+			}
+		}
+		fmt.Fprintf(w, "\n%s:\t%s\n", e.msg(core.MsgLabelElapsed), round(r.Elapsed))
+
+		// only the endpoints delayed by rate limits
+		header := true
+		for _, st := range r.Rates {
+			if st.Waited == 0 {
+				continue
+			}
+			if header {
+				fmt.Fprintf(w, "\n%s\n", e.msg(core.MsgTableRates))
+				header = false
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n",
+				st.Group, st.Host, st.Requests, st.Waited, round(st.Wait), round(st.MaxWait))
+		}
 	})
+}
 
-	defer jd.Release()
-	if err := jd.Login(); err == nil {
-		jd.RushBuy(gs)
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
 	}
+	return err.Error()
+}
+
+// splitRush split the goods across the profiles by the purchase limit,
+// and rush with all the profiles at the same time
+//
+func splitRush(e *env, items []core.RushItem, names []string) error {
+	jd := e.jingDong()
+	limits := make(map[string]int, len(items))
+	for _, item := range items {
+		sku, err := jd.SKUDetail(item.ID)
+		if err != nil {
+			return err
+		}
+		limits[item.ID] = sku.Limit
+	}
+
+	parts, err := core.SplitItems(items, len(names), func(ID string) int {
+		return limits[ID]
+	})
+	if err != nil {
+		return err
+	}
+
+	// login one by one, as the QR code may need to be scanned
+	jds := make([]*core.JingDong, len(names))
+	defer func() {
+		for _, jd := range jds {
+			if jd != nil {
+				jd.Release()
+			}
+		}
+	}()
+
+	for i, name := range names {
+		if len(parts[i]) == 0 {
+			continue
+		}
+		config := e.config
+		config.Profile = name
+		config.Proxy = e.proxyFor(name)
+		jds[i] = core.NewJingDong(config)
+		if err := jds[i].Login(); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		reports = make([]*core.RushReport, len(jds))
+		errs    = make([]error, len(jds))
+	)
+	for i, jd := range jds {
+		if jd == nil {
+			continue
+		}
+		clog.Info("profile %s: %+v", names[i], parts[i])
+		wg.Add(1)
+		go func(i int, jd *core.JingDong) {
+			defer wg.Done()
+			reports[i], errs[i] = jd.RushBuy(parts[i])
+		}(i, jd)
+	}
+	wg.Wait()
+
+	rerr := &core.RushError{}
+	for i, r := range reports {
+		if r != nil {
+			printReport(e, names[i], r)
+		}
+		if errs[i] != nil {
+			rerr.Errors = append(rerr.Errors, fmt.Errorf("profile %s: %w", names[i], errs[i]))
+		}
+	}
+
+	if len(rerr.Errors) > 0 {
+		return rerr
+	}
+	return nil
+}
+
+// parseStart parse the start time in RFC3339, or the clock time of today
+// like 10:00:00, empty for zero time
+//
+func parseStart(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("15:04:05", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, e.g. 10:00:00 or 2017-11-11T00:00:00+08:00", s)
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+}
+
+// rushItems return the goods from -goods, or from the job config if
+// -goods is not set
+//
+func rushItems(e *env) ([]core.RushItem, error) {
+	var items []core.RushItem
+	if e.job != nil && !e.set["goods"] {
+		for _, sku := range e.job.SKUs {
+			item := sku.rushItem()
+			for _, alt := range sku.Alternatives {
+				item.Alternatives = append(item.Alternatives, alt.rushItem())
+			}
+			item.FallbackAfter = sku.fallback
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	items, err := parseGoods(*goods)
+	for i := range items {
+		items[i].FallbackAfter = *fallback
+	}
+	return items, err
 }
 
 // parseGoods parse the input goods list. Support to input multiple goods sperated
 // by comma(,). With an (:count) after goods ID to specify the count of each goods.
+// The goods can also be the link of goods page.
 //
 // Example as following:
 //
@@ -68,23 +424,131 @@ func main() {
 //   2567304:3				single goods with count 3
 //   2567304,3133851:4		multiple goods with defferent count 1, 4
 //   2567304:2,3133851:5	...
+//   https://item.jd.com/2567304.html:2
+//   2567304:2|3133851:2	buy 3133851 instead if 2567304 is out of stock
 //
-func parseGoods(goods string) map[string]int {
-	lst := make(map[string]int)
-	if goods == "" {
-		return lst
-	}
-
-	for _, good := range strings.Split(goods, ",") {
-		pair := strings.Split(good, ":")
-		name := strings.Trim(pair[0], " ")
-		if len(pair) == 2 {
-			v, _ := strconv.ParseInt(pair[1], 10, 32)
-			lst[name] = int(v)
-		} else {
-			lst[name] = 1
+// All the malformed entries are reported in the error, the same goods
+// given more than once is rejected instead of overwritten.
+//
+func parseGoods(goods string) ([]core.RushItem, error) {
+	var (
+		items []core.RushItem
+		errs  []string
+		seen  = make(map[string]int)
+	)
+
+	if strings.TrimSpace(goods) == "" {
+		return nil, nil
+	}
+
+	for i, group := range strings.Split(goods, ",") {
+		var members []core.RushItem
+		for _, entry := range strings.Split(group, "|") {
+			item, err := parseGood(entry)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("#%d %q: %v", i+1, strings.TrimSpace(entry), err))
+				continue
+			}
+			if prev, ok := seen[item.ID]; ok {
+				errs = append(errs, fmt.Sprintf("#%d %q: duplicate goods %s, already given in #%d",
+					i+1, strings.TrimSpace(entry), item.ID, prev))
+				continue
+			}
+			seen[item.ID] = i + 1
+			members = append(members, item)
+		}
+
+		if len(members) > 0 {
+			item := members[0]
+			item.Alternatives = members[1:]
+			items = append(items, item)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid goods:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return items, nil
+}
+
+// parseGood parse a single goods entry, ID(:count) or link(:count)
+//
+func parseGood(entry string) (core.RushItem, error) {
+	item := core.RushItem{Count: 1}
+
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return item, fmt.Errorf("empty entry")
+	}
+
+	// the scheme of link conflicts with the count separator
+	for _, scheme := range []string{"https://", "http://", "//"} {
+		if strings.HasPrefix(strings.ToLower(entry), scheme) {
+			entry = entry[len(scheme):]
+			break
+		}
+	}
+
+	pair := strings.Split(entry, ":")
+	if len(pair) > 2 {
+		return item, fmt.Errorf("too many ':'")
+	}
+
+	ID, err := parseGoodID(strings.TrimSpace(pair[0]))
+	if err != nil {
+		return item, err
+	}
+	item.ID = ID
+
+	if len(pair) == 2 {
+		count, err := strconv.Atoi(strings.TrimSpace(pair[1]))
+		if err != nil {
+			return item, fmt.Errorf("invalid count %q", pair[1])
+		}
+		if count < 1 || count > maxGoodsCount {
+			return item, fmt.Errorf("count %d out of range [1, %d]", count, maxGoodsCount)
+		}
+		item.Count = count
+	}
+	return item, nil
+}
+
+// parseGoodID return the goods ID, which is numeric or the link of goods
+// page without scheme, e.g. item.jd.com/2567304.html
+//
+func parseGoodID(s string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("empty goods ID")
+	}
+
+	if strings.Contains(s, "/") {
+		if n := strings.IndexAny(s, "?#"); n >= 0 {
+			s = s[:n]
+		}
+		pos := strings.Index(s, "/")
+		host, path := strings.ToLower(s[:pos]), s[pos+1:]
+		if host != "item.jd.com" || !strings.HasSuffix(path, ".html") {
+			return "", fmt.Errorf("not a goods link, e.g. https://item.jd.com/2567304.html")
 		}
+		s = strings.TrimSuffix(path, ".html")
 	}
 
-	return lst
+	if _, err := strconv.ParseUint(s, 10, 64); err != nil {
+		return "", fmt.Errorf("goods ID %q is not numeric", s)
+	}
+	return s, nil
+}
+
+// parseIDs split the sku IDs from args, separated by space or comma
+//
+func parseIDs(args []string) []string {
+	var ids []string
+	for _, arg := range args {
+		for _, id := range strings.Split(arg, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adyzng/go-jd/core"
+)
+
+// rushItem return the item parsed without alternatives
+//
+func rushItem(id string, count int) core.RushItem {
+	return core.RushItem{ID: id, Count: count, Alternatives: []core.RushItem{}}
+}
+
+func TestParseGoods(t *testing.T) {
+	tests := []struct {
+		goods string
+		want  []core.RushItem
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"531065", []core.RushItem{rushItem("531065", 1)}},
+		{" 531065 : 2 ,3133811", []core.RushItem{
+			rushItem("531065", 2),
+			rushItem("3133811", 1),
+		}},
+		{"https://item.jd.com/2567304.html:3", []core.RushItem{rushItem("2567304", 3)}},
+		{"//item.jd.com/2567304.html?spm=1#comment", []core.RushItem{rushItem("2567304", 1)}},
+		{"HTTP://ITEM.JD.COM/2567304.html", []core.RushItem{rushItem("2567304", 1)}},
+		{"531065:200", []core.RushItem{rushItem("531065", 200)}},
+		{"531065:2|3133811|http://item.jd.com/2567304.html:1", []core.RushItem{{
+			ID:    "531065",
+			Count: 2,
+			Alternatives: []core.RushItem{
+				{ID: "3133811", Count: 1},
+				{ID: "2567304", Count: 1},
+			},
+		}}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseGoods(tt.goods)
+		if err != nil {
+			t.Errorf("parseGoods(%q): %v", tt.goods, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseGoods(%q) = %+v, want %+v", tt.goods, got, tt.want)
+		}
+	}
+}
+
+func TestParseGoodsErrors(t *testing.T) {
+	tests := []struct {
+		goods string
+		err   string // substring of the error
+	}{
+		{"531065,", "empty entry"},
+		{"531065||3133811", "empty entry"},
+		{"531065:0", "out of range"},
+		{"531065:-1", "out of range"},
+		{"531065:201", "out of range"},
+		{"531065:two", "invalid count"},
+		{"531065:", "invalid count"},
+		{"531065:1:2", "too many ':'"},
+		{"abc", "not numeric"},
+		{"-531065", "not numeric"},
+		{":2", "empty goods ID"},
+		{"https://item.jd.com/abc.html", "not numeric"},
+		{"https://item.taobao.com/2567304.html", "not a goods link"},
+		{"https://item.jd.com/2567304", "not a goods link"},
+		{"531065,531065:2", "duplicate goods 531065, already given in #1"},
+		{"531065|https://item.jd.com/531065.html", "duplicate goods 531065"},
+		{"3133811|531065,531065", "already given in #1"},
+	}
+
+	for _, tt := range tests {
+		items, err := parseGoods(tt.goods)
+		if err == nil {
+			t.Errorf("parseGoods(%q) = %+v, want error", tt.goods, items)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseGoods(%q): %v, want %q", tt.goods, err, tt.err)
+		}
+	}
+}
+
+func TestParseGoodsAllErrors(t *testing.T) {
+	_, err := parseGoods("abc,531065:0,3133811")
+	if err == nil {
+		t.Fatal("parseGoods succeeded, want error")
+	}
+	// each invalid entry is reported with its position
+	for _, s := range []string{`#1 "abc"`, `#2 "531065:0"`} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error %q does not contain %q", err, s)
+		}
+	}
+	if strings.Contains(err.Error(), "#3") {
+		t.Errorf("error %q contains the valid entry #3", err)
+	}
+}
+
+func TestParseGoodID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+		ok   bool
+	}{
+		{"531065", "531065", true},
+		{"item.jd.com/531065.html", "531065", true},
+		{"item.jd.com/531065.html?x=1", "531065", true},
+		{"ITEM.JD.COM/531065.html", "531065", true},
+		{"", "", false},
+		{"1.5", "", false},
+		{"item.jd.com/", "", false},
+		{"item.jd.com/.html", "", false},
+		{"www.jd.com/531065.html", "", false},
+		{"99999999999999999999999", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseGoodID(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseGoodID(%q) = %q, %v, want %q, ok %v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseIDs(t *testing.T) {
+	got := parseIDs([]string{"1, 2", "", " 3 ,,", "4"})
+	if want := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseIDs = %q, want %q", got, want)
+	}
+	if got := parseIDs(nil); got != nil {
+		t.Errorf("parseIDs(nil) = %q, want nil", got)
+	}
+}
@@ -0,0 +1,834 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adyzng/go-jd/core"
+	clog "gopkg.in/clog.v1"
+)
+
+// command is a subcommand of autobuy
+//
+type command struct {
+	Name  string
+	Args  string // synopsis of the positional arguments
+	Short string // one line description
+	Long  string // help text
+
+	Flags func(fs *flag.FlagSet) // register command specific flags
+	Run   func(e *env, args []string) error
+
+	Verbose bool // log at INFO level, otherwise only warnings and errors
+	Events  bool // print the phase events in json output
+}
+
+var commands = []*command{
+	{
+		Name:    "login",
+		Short:   "login by QR code and save the cookies",
+		Long:    "Login JD by scanning the QR code with JD app, the cookies are saved for later commands.",
+		Run:     runLogin,
+		Verbose: true,
+		Events:  true,
+	},
+	{
+		Name:  "logout",
+		Short: "clear the saved cookies",
+		Long:  "Logout clears the cookies and removes the cookies file.",
+		Run:   runLogout,
+	},
+	{
+		Name:  "whoami",
+		Short: "show the current login session",
+		Long:  "Whoami validates the saved session and shows the user, login time and estimated expiry.",
+		Run:   runWhoami,
+	},
+	{
+		Name:  "cart",
+		Args:  "[list | select|unselect|select-only|remove <id>[,<id>...] | clear]",
+		Short: "show or manage the shopping cart",
+		Long: `Cart list shows the goods in the shopping cart, + marks the selected ones.
+Cart select, unselect and remove check, uncheck and remove the goods, select-only
+checks the goods and unchecks all the others, clear removes all the goods.
+The cart is shown after the change.`,
+		Run: runCart,
+	},
+	{
+		Name:  "address",
+		Args:  "list | select <id|keyword>",
+		Short: "list or select the consignee address",
+		Long: `Address list shows the consignee addresses saved in the account, + marks the one
+used by the order. Address select chooses the address of the order by ID, or
+by the only address whose name, mobile or detail contains the keyword.`,
+		Run: runAddress,
+	},
+	{
+		Name:  "order",
+		Args:  "preview|submit",
+		Short: "preview or submit the order",
+		Long: `Order preview shows the checkout page summary of the selected goods in cart.
+Order submit submits the order of the selected goods in cart.`,
+		Run: runOrder,
+	},
+	{
+		Name:  "stock",
+		Args:  "<id>[,<id>...]",
+		Short: "show the stock state of goods",
+		Long:  "Stock shows the stock state of goods in the ship area.",
+		Run:   runStock,
+	},
+	{
+		Name:  "price",
+		Args:  "<id>[,<id>...]",
+		Short: "show the price of goods",
+		Long:  "Price shows the current price of goods.",
+		Run:   runPrice,
+	},
+	{
+		Name:  "sku",
+		Args:  "<id>",
+		Short: "show the goods details",
+		Long:  "Sku shows the name, price, stock state and cart link of goods.",
+		Run:   runSKU,
+	},
+	{
+		Name:  "rush",
+		Short: "rush to buy goods (default command)",
+		Long: `Rush adds the goods to cart once they are in stock, and submits the order
+if -order is set. It is the default command when no command is given.`,
+		Flags:   rushFlags,
+		Run:     runRush,
+		Verbose: true,
+		Events:  true,
+	},
+	{
+		Name:  "area",
+		Args:  "lookup <names|code> | search <keyword>",
+		Short: "resolve the ship area code",
+		Long: `Area lookup resolves the names like 北京/朝阳区/三环以内 or beijing/chaoyang/sanhuanyinei
+to the area code used by -area, or shows the names of an area code.
+Area search lists the areas whose name or pinyin contains the keyword.
+
+The area names can also be used by -area directly. The embedded dataset is
+only a sample of about 40 areas, use -areas to load a full one with the same
+format as core/areas.tsv.`,
+		Run: runArea,
+	},
+	{
+		Name:  "watch",
+		Args:  "<id>[,<id>...]",
+		Short: "watch the stock state changes of goods",
+		Long: `Watch polls the stock state of goods and prints the changes until interrupted,
+or until any of them is in stock if -until is set.`,
+		Flags:   watchFlags,
+		Run:     runWatch,
+		Verbose: true,
+	},
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n  autobuy <command> [flags] [args]\n\nCommands:\n")
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.Name, cmd.Short)
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "\nUse \"autobuy help <command>\" for more information about a command.\n")
+}
+
+func help(args []string) {
+	if len(args) == 0 {
+		usage()
+		return
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+	cmd.flagSet(&options{}).Usage()
+}
+
+// options shared by all commands
+//
+type options struct {
+	area    string
+	lang    string
+	output  string
+	keep    int
+	config  string
+	profile string
+	areas   string
+	rate    string
+	retries int
+	record  string
+	proxy   string
+	pool    string
+}
+
+func (cmd *command) flagSet(opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ExitOnError)
+	fs.StringVar(&opts.area, "area", AreaBeijing, "ship location code or names like 北京/朝阳区/三环以内, default to Beijing")
+	fs.StringVar(&opts.areas, "areas", "", "area dataset file to resolve the area names, default to the embedded one.")
+	fs.StringVar(&opts.lang, "lang", "", "language of messages, zh-CN or en-US. default from $LANG.")
+	fs.StringVar(&opts.output, "output", OutputText, "output format, text or json.")
+	fs.StringVar(&opts.config, "config", "", "job config file, .yaml, .toml or .json. flags set explicitly override it.")
+	fs.StringVar(&opts.profile, "profile", "", "account profile, each profile keeps its own cookies.")
+	fs.StringVar(&opts.proxy, "proxy", "", "HTTP or SOCKS5 proxy of the profile, e.g. socks5://127.0.0.1:1080. default from $HTTPS_PROXY.")
+	fs.StringVar(&opts.pool, "proxy-pool", "", "proxies separated by comma to rotate for the stock and price queries.")
+	fs.IntVar(&opts.retries, "retries", 2, "retry the idempotent requests on network errors, and the order list check if the submission fails midway.")
+	fs.StringVar(&opts.record, "record", "", "append each request as a JSON line to the file, for troubleshooting.")
+	fs.StringVar(&opts.rate, "rate", "", "request rate limits shared by profiles, e.g. stock=10/5/4,order=2 for group=rate[/burst[/max-in-flight]].\ngroups: stock, price, cart, order, other.")
+	if cmd.Verbose {
+		fs.IntVar(&opts.keep, "keepalive", 0, "validate and refresh the login session periodically, unit: minute. 0 to disable.")
+	}
+	if cmd.Flags != nil {
+		cmd.Flags(fs)
+	}
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  autobuy %s [flags] %s\n\n%s\n\nFlags:\n", cmd.Name, cmd.Args, cmd.Long)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// execute parse the flags and run the command, return the exit code
+//
+func (cmd *command) execute(args []string) int {
+	e := &env{}
+	fs := cmd.flagSet(&e.opts)
+	fs.Parse(args)
+
+	e.set = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { e.set[f.Name] = true })
+
+	if e.opts.areas != "" {
+		if err := loadAreas(e.opts.areas); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	if e.opts.config != "" {
+		job, err := loadConfig(e.opts.config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		e.job = job
+		e.applyConfig()
+	}
+
+	area, err := core.ResolveArea(e.opts.area)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-area: %v\n", err)
+		return 2
+	}
+	e.opts.area = area
+
+	limits, err := core.ParseRateLimits(e.opts.rate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-rate: %v\n", err)
+		return 2
+	}
+	if e.job != nil {
+		for g, l := range e.job.rateLimits() {
+			if _, ok := limits[g]; !ok {
+				limits[g] = l
+			}
+		}
+	}
+	core.SetRateLimits(limits)
+
+	level := clog.WARN
+	if cmd.Verbose {
+		level = clog.INFO
+	}
+	initLog(level, e.opts.output == OutputJSON)
+	defer clog.Shutdown()
+
+	lang := core.EnvLang()
+	if e.opts.lang != "" {
+		lang = core.ParseLang(e.opts.lang)
+	}
+
+	e.config = core.JDConfig{
+		ShipArea:   e.opts.area,
+		Profile:    e.opts.profile,
+		KeepAlive:  time.Minute * time.Duration(e.opts.keep),
+		Logger:     core.ClogLogger{},
+		Lang:       lang,
+		MaxRetries: e.opts.retries,
+	}
+	if e.config.Proxy = e.proxyFor(e.opts.profile); e.config.Proxy != "" {
+		if _, err := core.ParseProxy(e.config.Proxy); err != nil {
+			fmt.Fprintf(os.Stderr, "-proxy: %v\n", err)
+			return 2
+		}
+	}
+	if proxies := parseIDs([]string{e.opts.pool}); len(proxies) > 0 {
+		if e.config.ProxyPool, err = core.NewProxyPool(proxies); err != nil {
+			fmt.Fprintf(os.Stderr, "-proxy-pool: %v\n", err)
+			return 2
+		}
+	}
+	if e.opts.retries == 0 {
+		e.config.MaxRetries = -1 // 0 is the default of JDConfig
+	}
+
+	if e.opts.record != "" {
+		f, err := os.OpenFile(e.opts.record, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-record: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		e.config.Middlewares = append(e.config.Middlewares, core.RecordMiddleware(f))
+	}
+
+	if job := e.job; job != nil {
+		e.config.MaxPayment = job.Guards.MaxPayment
+		e.config.Deadline = job.deadline
+		e.config.PolicyDeadline = job.policyDeadline
+		if job.Notifier.Type == "webhook" {
+			e.config.Notifier = &core.WebhookNotifier{URL: job.Notifier.URL}
+		}
+	}
+
+	switch e.opts.output {
+	case OutputText:
+	case OutputJSON:
+		// the logs are written to stderr by initLog, stdout is json only
+		if cmd.Events {
+			e.config.OnEvent = newJSONEmitter(os.Stdout).Emit
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format: %s\n", e.opts.output)
+		return 2
+	}
+
+	err = cmd.Run(e, fs.Args())
+	if e.jd != nil {
+		e.jd.Release()
+	}
+
+	if err != nil {
+		if e.opts.output == OutputJSON {
+			e.print(map[string]string{"error": err.Error()}, nil)
+		} else {
+			fmt.Fprintf(os.Stderr, "autobuy %s: %v\n", cmd.Name, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// env is the running environment of a command
+//
+type env struct {
+	opts   options
+	set    map[string]bool // flags set on command line
+	job    *jobConfig      // loaded by -config, may be nil
+	config core.JDConfig
+	jd     *core.JingDong
+}
+
+// applyConfig copy the common options from job config, unless the flag
+// is set explicitly on command line
+//
+func (e *env) applyConfig() {
+	job := e.job
+	if job.Area != "" && !e.set["area"] {
+		e.opts.area = job.Area
+	}
+	if job.Lang != "" && !e.set["lang"] {
+		e.opts.lang = job.Lang
+	}
+	if job.Profile != "" && !e.set["profile"] {
+		e.opts.profile = job.Profile
+	}
+	if job.KeepAlive > 0 && !e.set["keepalive"] {
+		e.opts.keep = job.KeepAlive
+	}
+	if job.Proxy != "" && !e.set["proxy"] {
+		e.opts.proxy = job.Proxy
+	}
+	if len(job.ProxyPool) > 0 && !e.set["proxy-pool"] {
+		e.opts.pool = strings.Join(job.ProxyPool, ",")
+	}
+	if job.Retries != nil && !e.set["retries"] {
+		e.opts.retries = *job.Retries
+	}
+}
+
+// proxyFor return the proxy of profile, the proxies of config by profile
+// take precedence over -proxy, unless it is set for the current profile
+//
+func (e *env) proxyFor(profile string) string {
+	if e.set["proxy"] && profile == e.opts.profile {
+		return e.opts.proxy
+	}
+	if e.job != nil {
+		if proxy, ok := e.job.Proxies[profile]; ok {
+			return proxy
+		}
+	}
+	return e.opts.proxy
+}
+
+// jingDong create the JingDong object on first use
+//
+func (e *env) jingDong() *core.JingDong {
+	if e.jd == nil {
+		e.jd = core.NewJingDong(e.config)
+	}
+	return e.jd
+}
+
+// requireLogin return the JingDong object with a valid session
+//
+func (e *env) requireLogin() (*core.JingDong, error) {
+	jd := e.jingDong()
+	if _, err := jd.Session(); err != nil {
+		if errors.Is(err, core.ErrNotLoggedIn) {
+			return nil, fmt.Errorf("%w, run `autobuy login` first", err)
+		}
+		return nil, err
+	}
+	return jd, nil
+}
+
+// msg return the message of id in the configured language
+//
+func (e *env) msg(id core.MsgID) string {
+	return core.Message(e.config.Lang, id)
+}
+
+// print v as json in json output, or call text
+//
+func (e *env) print(v interface{}, text func(w *tabwriter.Writer)) {
+	if e.opts.output == OutputJSON || text == nil {
+		json.NewEncoder(os.Stdout).Encode(v)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	text(w)
+	w.Flush()
+}
+
+func printSession(e *env, ss *core.Session) {
+	e.print(ss, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelUser), ss.User)
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelNick), ss.Nick)
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelLogin), ss.LoginTime.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelExpires), ss.Expires.Format("2006-01-02 15:04:05"))
+	})
+}
+
+func runLogin(e *env, args []string) error {
+	jd := e.jingDong()
+	if err := jd.Login(); err != nil {
+		return err
+	}
+
+	ss, err := jd.Session()
+	if err != nil {
+		return err
+	}
+	printSession(e, ss)
+	return nil
+}
+
+func runLogout(e *env, args []string) error {
+	return e.jingDong().Logout()
+}
+
+func runWhoami(e *env, args []string) error {
+	ss, err := e.jingDong().Session()
+	if err != nil {
+		return err
+	}
+	printSession(e, ss)
+	return nil
+}
+
+func runCart(e *env, args []string) error {
+	jd, err := e.requireLogin()
+	if err != nil {
+		return err
+	}
+
+	var (
+		action = "list"
+		ids    []string
+	)
+	if len(args) > 0 {
+		action, ids = args[0], parseIDs(args[1:])
+	}
+
+	switch action {
+	case "list":
+	case "clear":
+		err = jd.ClearCart()
+	case "select", "unselect", "select-only", "remove":
+		if len(ids) == 0 {
+			return fmt.Errorf("cart %s needs the goods ID", action)
+		}
+		switch action {
+		case "select":
+			err = jd.SelectItems(ids...)
+		case "unselect":
+			err = jd.UnselectItems(ids...)
+		case "select-only":
+			err = jd.SelectOnly(ids...)
+		case "remove":
+			err = jd.RemoveFromCart(ids...)
+		}
+	default:
+		return fmt.Errorf("unknown cart command: %s", action)
+	}
+	if err != nil {
+		return err
+	}
+
+	cart, err := jd.CartDetails()
+	if err != nil {
+		return err
+	}
+
+	e.print(cart, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, e.msg(core.MsgTableCart))
+		for _, item := range cart.Items {
+			check := "-"
+			if item.Checked {
+				check = "+"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n",
+				check, item.ID, item.Count, item.Price, item.Total, item.Name)
+		}
+		fmt.Fprintf(w, "\n%s:\t%s\n", e.msg(core.MsgLabelSelected), cart.Count)
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelTotal), cart.Total)
+	})
+	return nil
+}
+
+func runOrder(e *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("order needs one of preview or submit")
+	}
+
+	jd, err := e.requireLogin()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "preview":
+		order, err := jd.OrderInfo()
+		if err != nil {
+			return err
+		}
+		e.print(order, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelGoods), order.WarePrice)
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelShipping), order.ShipPrice)
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelPayment), order.Payment)
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelMobile), order.Mobile)
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelAddress), order.Address)
+		})
+
+	case "submit":
+		orderID, err := jd.SubmitOrder()
+		if err != nil {
+			return err
+		}
+		e.print(map[string]string{"order_id": orderID}, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelOrder), orderID)
+		})
+
+	default:
+		return fmt.Errorf("unknown order command: %s", args[0])
+	}
+	return nil
+}
+
+func runAddress(e *env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("address needs list or select <id|keyword>")
+	}
+
+	jd, err := e.requireLogin()
+	if err != nil {
+		return err
+	}
+
+	var addrs []*core.Address
+	switch args[0] {
+	case "list":
+		if addrs, err = jd.Addresses(); err != nil {
+			return err
+		}
+
+	case "select":
+		if len(args) != 2 {
+			return fmt.Errorf("address select needs the ID or keyword")
+		}
+		addr, err := jd.SelectAddress(args[1])
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+
+	default:
+		return fmt.Errorf("unknown address command: %s", args[0])
+	}
+
+	e.print(addrs, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, e.msg(core.MsgTableAddress))
+		for _, addr := range addrs {
+			check := "-"
+			if addr.Selected {
+				check = "+"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				check, addr.ID, addr.Name, addr.Mobile, addr.Area, addr.Detail)
+		}
+	})
+	return nil
+}
+
+// stockResult is the stock state of a goods
+//
+type stockResult struct {
+	ID        string `json:"id"`
+	State     string `json:"state"`
+	StateName string `json:"state_name"`
+}
+
+func runStock(e *env, args []string) error {
+	ids := parseIDs(args)
+	if len(ids) == 0 {
+		return fmt.Errorf("no goods ID specified")
+	}
+
+	jd := e.jingDong()
+	results := make([]stockResult, 0, len(ids))
+	for _, id := range ids {
+		state, name, err := jd.StockState(id)
+		if err != nil {
+			return err
+		}
+		results = append(results, stockResult{ID: id, State: state, StateName: name})
+	}
+
+	e.print(results, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, e.msg(core.MsgTableStock))
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, r.State, r.StateName)
+		}
+	})
+	return nil
+}
+
+func runPrice(e *env, args []string) error {
+	ids := parseIDs(args)
+	if len(ids) == 0 {
+		return fmt.Errorf("no goods ID specified")
+	}
+
+	jd := e.jingDong()
+	prices := make(map[string]string, len(ids))
+	for _, id := range ids {
+		price, err := jd.Price(id)
+		if err != nil {
+			return err
+		}
+		prices[id] = price
+	}
+
+	e.print(prices, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, e.msg(core.MsgTablePrice))
+		for _, id := range ids {
+			fmt.Fprintf(w, "%s\t%s\n", id, prices[id])
+		}
+	})
+	return nil
+}
+
+func runSKU(e *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("sku needs exactly one goods ID")
+	}
+
+	sku, err := e.jingDong().SKUDetail(args[0])
+	if err != nil {
+		return err
+	}
+
+	e.print(sku, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelID), sku.ID)
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelName), sku.Name)
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelPrice), sku.Price)
+		fmt.Fprintf(w, "%s:\t%s (%s)\n", e.msg(core.MsgLabelStock), sku.StateName, sku.State)
+		fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelLink), sku.Link)
+		if sku.VenderID != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", e.msg(core.MsgLabelVender), sku.VenderID)
+		}
+	})
+	return nil
+}
+
+// loadAreas replace the default area dataset with the file
+//
+func loadAreas(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db, err := core.LoadAreaDB(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	core.SetAreaDB(db)
+	return nil
+}
+
+// areaResult is an area with the full code and names
+//
+type areaResult struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func newAreaResult(a *core.Area) areaResult {
+	return areaResult{Code: a.FullCode(), Name: a.FullName()}
+}
+
+func runArea(e *env, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("area needs lookup <names|code> or search <keyword>")
+	}
+
+	db, err := core.DefaultAreaDB()
+	if err != nil {
+		return err
+	}
+
+	var results []areaResult
+	switch args[0] {
+	case "lookup":
+		var a *core.Area
+		if strings.Trim(args[1], "_0123456789") == "" {
+			a, err = db.Lookup(args[1])
+		} else {
+			a, err = db.Resolve(args[1])
+		}
+		if err != nil {
+			return err
+		}
+		results = append(results, newAreaResult(a))
+
+	case "search":
+		for _, a := range db.Search(args[1]) {
+			results = append(results, newAreaResult(a))
+		}
+		if len(results) == 0 {
+			return fmt.Errorf("%w: no area matches %q", core.ErrInvalidArea, args[1])
+		}
+
+	default:
+		return fmt.Errorf("unknown area command: %s", args[0])
+	}
+
+	e.print(results, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, e.msg(core.MsgTableArea))
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\n", r.Code, r.Name)
+		}
+	})
+	return nil
+}
+
+// watch flags
+var (
+	watchPeriod *int
+	watchUntil  *bool
+)
+
+func watchFlags(fs *flag.FlagSet) {
+	watchPeriod = fs.Int("period", 1000, "the refresh period, unit: ms.")
+	watchUntil = fs.Bool("until", false, "exit when any of the goods is in stock.")
+}
+
+func runWatch(e *env, args []string) error {
+	ids := parseIDs(args)
+	if len(ids) == 0 && e.job != nil {
+		for _, sku := range e.job.SKUs {
+			ids = append(ids, sku.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no goods ID specified")
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	jd := e.jingDong()
+	last := make(map[string]string, len(ids))
+	for {
+		for _, id := range ids {
+			state, name, err := jd.StockState(id)
+			if err != nil {
+				clog.Warn("%s: %v", id, err)
+				continue
+			}
+			if last[id] == state {
+				continue
+			}
+
+			last[id] = state
+			r := struct {
+				Time time.Time `json:"time"`
+				stockResult
+			}{time.Now(), stockResult{ID: id, State: state, StateName: name}}
+			e.print(r, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Time.Format("15:04:05.000"), id, state, strings.TrimSpace(name))
+			})
+
+			// 34 : out of stock
+			if *watchUntil && state != "34" {
+				return nil
+			}
+		}
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-time.After(time.Millisecond * time.Duration(*watchPeriod)):
+		}
+	}
+}
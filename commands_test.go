@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adyzng/go-jd/core"
+)
+
+func TestLoadAreas(t *testing.T) {
+	defer core.SetAreaDB(nil)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "areas.tsv")
+	data := "22\t0\t四川\tsichuan\n1930\t22\t成都市\tchengdu\n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadAreas(filename); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := core.ResolveArea("sichuan/chengdu"); err != nil || got != "22_1930_0_0" {
+		t.Errorf("ResolveArea = %q, %v, want 22_1930_0_0", got, err)
+	}
+	// the embedded dataset is replaced
+	if _, err := core.ResolveArea("北京/朝阳区/三环以内"); err == nil {
+		t.Error("ResolveArea of the embedded area succeeded, want error")
+	}
+
+	if err := loadAreas(filepath.Join(dir, "none.tsv")); err == nil {
+		t.Error("loadAreas of missing file succeeded, want error")
+	}
+	bad := filepath.Join(dir, "bad.tsv")
+	if err := os.WriteFile(bad, []byte("1930\t22\t成都市\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadAreas(bad); err == nil {
+		t.Error("loadAreas of unknown parent succeeded, want error")
+	}
+}
@@ -0,0 +1,642 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/adyzng/go-jd/core"
+	"gopkg.in/yaml.v3"
+)
+
+// jobConfig describe a rush job, loaded by -config from a YAML, TOML or
+// JSON file. Example in YAML:
+//
+//   profile: alice
+//   area: 1_72_2799_0
+//   period: 500
+//   rush: true
+//   order: true
+//   order_policy: all
+//   keepalive: 10
+//   address: 朝阳区
+//   guards:
+//     max_payment: 5000
+//     deadline: 2017-11-11T00:30:00+08:00
+//     policy_deadline: 2017-11-11T00:05:00+08:00
+//   limit:
+//     policy: split
+//     profiles: [alice, bob]
+//   notifier:
+//     type: webhook
+//     url: https://example.com/jd-hook
+//   skus:
+//     - id: "2567304"
+//       count: 2
+//       max_price: 1999
+//       priority: 10
+//       fallback_after: 30s
+//       alternatives:
+//         - id: "3133851"
+//         - id: "4099139"
+//
+type jobConfig struct {
+	Profile     string                `yaml:"profile" toml:"profile" json:"profile"`
+	Area        string                `yaml:"area" toml:"area" json:"area"`
+	Lang        string                `yaml:"lang" toml:"lang" json:"lang"`
+	Period      int                   `yaml:"period" toml:"period" json:"period"` // ms
+	Rush        *bool                 `yaml:"rush" toml:"rush" json:"rush"`
+	Order       *bool                 `yaml:"order" toml:"order" json:"order"`
+	OrderPolicy string                `yaml:"order_policy" toml:"order_policy" json:"order_policy"` // any, all or min-N
+	KeepAlive   int                   `yaml:"keepalive" toml:"keepalive" json:"keepalive"`          // minute
+	Address     string                `yaml:"address" toml:"address" json:"address"`                // ID or keyword
+	Workers     int                   `yaml:"workers" toml:"workers" json:"workers"`
+	Retries     *int                  `yaml:"retries" toml:"retries" json:"retries"`          // retries of idempotent requests
+	Proxy       string                `yaml:"proxy" toml:"proxy" json:"proxy"`                // proxy of the profile
+	Proxies     map[string]string     `yaml:"proxies" toml:"proxies" json:"proxies"`          // proxy by profile
+	ProxyPool   []string              `yaml:"proxy_pool" toml:"proxy_pool" json:"proxy_pool"` // rotated for stock and price
+	Poll        pollConfig            `yaml:"poll" toml:"poll" json:"poll"`
+	RateLimits  map[string]rateConfig `yaml:"rate_limits" toml:"rate_limits" json:"rate_limits"`
+	Guards      guardConfig           `yaml:"guards" toml:"guards" json:"guards"`
+	Limit       limitConfig           `yaml:"limit" toml:"limit" json:"limit"`
+	Notifier    notifierConfig        `yaml:"notifier" toml:"notifier" json:"notifier"`
+	SKUs        []skuConfig           `yaml:"skus" toml:"skus" json:"skus"`
+
+	deadline       time.Time
+	policyDeadline time.Time
+}
+
+type guardConfig struct {
+	MaxPayment     float64 `yaml:"max_payment" toml:"max_payment" json:"max_payment"`
+	Deadline       string  `yaml:"deadline" toml:"deadline" json:"deadline"`                      // RFC3339
+	PolicyDeadline string  `yaml:"policy_deadline" toml:"policy_deadline" json:"policy_deadline"` // RFC3339
+}
+
+type pollConfig struct {
+	Strategy  string `yaml:"strategy" toml:"strategy" json:"strategy"`       // fixed, jitter, backoff or burst
+	Start     string `yaml:"start" toml:"start" json:"start"`                // start time of sale for burst
+	Window    string `yaml:"window" toml:"window" json:"window"`             // burst window, e.g. 5s
+	MaxErrors int    `yaml:"max_errors" toml:"max_errors" json:"max_errors"` // consecutive errors before giving up
+
+	window time.Duration
+}
+
+type rateConfig struct {
+	Rate        float64 `yaml:"rate" toml:"rate" json:"rate"`                            // requests per second
+	Burst       int     `yaml:"burst" toml:"burst" json:"burst"`                         // requests allowed at once
+	MaxInFlight int     `yaml:"max_in_flight" toml:"max_in_flight" json:"max_in_flight"` // requests at the same time
+}
+
+type limitConfig struct {
+	Policy   string   `yaml:"policy" toml:"policy" json:"policy"`       // fail, clamp or split
+	Profiles []string `yaml:"profiles" toml:"profiles" json:"profiles"` // to split the goods
+}
+
+type notifierConfig struct {
+	Type string `yaml:"type" toml:"type" json:"type"` // desktop or webhook
+	URL  string `yaml:"url" toml:"url" json:"url"`
+}
+
+type skuConfig struct {
+	ID       string  `yaml:"id" toml:"id" json:"id"`
+	Count    int     `yaml:"count" toml:"count" json:"count"`
+	MaxPrice float64 `yaml:"max_price" toml:"max_price" json:"max_price"`
+	Priority int     `yaml:"priority" toml:"priority" json:"priority"`
+
+	// bought instead if the goods is not in stock within FallbackAfter
+	Alternatives  []skuConfig `yaml:"alternatives" toml:"alternatives" json:"alternatives"`
+	FallbackAfter string      `yaml:"fallback_after" toml:"fallback_after" json:"fallback_after"` // e.g. 30s
+
+	fallback time.Duration
+}
+
+// rushItem convert the goods to RushItem, count defaults to 1
+//
+func (sku *skuConfig) rushItem() core.RushItem {
+	item := core.RushItem{
+		ID:       sku.ID,
+		Count:    sku.Count,
+		MaxPrice: sku.MaxPrice,
+		Priority: sku.Priority,
+	}
+	if item.Count == 0 {
+		item.Count = 1
+	}
+	return item
+}
+
+// knownKeys is the schema of the config file, sequence indexes are removed
+//
+var knownKeys = map[string]bool{
+	"profile": true, "area": true, "lang": true, "period": true,
+	"rush": true, "order": true, "keepalive": true, "address": true, "workers": true,
+	"retries": true, "order_policy": true,
+	"proxy": true, "proxies": true, "proxy_pool": true,
+	"guards": true, "guards.max_payment": true, "guards.deadline": true, "guards.policy_deadline": true,
+	"poll": true, "poll.strategy": true, "poll.start": true, "poll.window": true, "poll.max_errors": true,
+	"limit": true, "limit.policy": true, "limit.profiles": true,
+	"notifier": true, "notifier.type": true, "notifier.url": true,
+	"skus": true, "skus.id": true, "skus.count": true, "skus.max_price": true, "skus.priority": true,
+	"skus.fallback_after": true, "skus.alternatives": true, "skus.alternatives.id": true,
+	"skus.alternatives.count": true, "skus.alternatives.max_price": true,
+}
+
+func init() {
+	knownKeys["rate_limits"] = true
+	for _, g := range rateGroups {
+		for _, key := range []string{"", ".rate", ".burst", ".max_in_flight"} {
+			knownKeys["rate_limits."+string(g)+key] = true
+		}
+	}
+}
+
+var rateGroups = []core.EndpointGroup{
+	core.GroupStock, core.GroupPrice, core.GroupCart, core.GroupOrder, core.GroupOther,
+}
+
+var (
+	reIndex   = regexp.MustCompile(`\[\d+\]`)
+	reSKUID   = regexp.MustCompile(`^\d+$`)
+	reLineNum = regexp.MustCompile(`line (\d+)`)
+)
+
+// fieldError is an error at a key of the config file
+//
+type fieldError struct {
+	Line int
+	Path string
+	Msg  string
+}
+
+// configError holds all the errors found in the config file
+//
+type configError struct {
+	File   string
+	Errors []fieldError
+}
+
+func (e *configError) Error() string {
+	var buf bytes.Buffer
+	for i, fe := range e.Errors {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(e.File)
+		if fe.Line > 0 {
+			fmt.Fprintf(&buf, ":%d", fe.Line)
+		}
+		if fe.Path != "" {
+			fmt.Fprintf(&buf, ": %s", fe.Path)
+		}
+		fmt.Fprintf(&buf, ": %s", fe.Msg)
+	}
+	return buf.String()
+}
+
+// loadConfig read and validate the job config, the format is detected
+// by the file extension.
+//
+func loadConfig(filename string) (*jobConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cfg   = &jobConfig{}
+		lines map[string]int
+		errs  []fieldError
+	)
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		lines, errs = decodeYAML(data, cfg)
+	case ".toml":
+		lines, errs = decodeTOML(data, cfg)
+	case ".json":
+		lines, errs = decodeJSON(data, cfg)
+	default:
+		return nil, fmt.Errorf("%s: unknown config format, use .yaml, .toml or .json", filename)
+	}
+
+	if len(errs) == 0 {
+		for path, line := range lines {
+			key := reIndex.ReplaceAllString(path, "")
+			if !knownKeys[key] && !strings.HasPrefix(key, "proxies.") { // keyed by profile
+				errs = append(errs, fieldError{Line: line, Path: path, Msg: "unknown key"})
+			}
+		}
+		for _, fe := range cfg.validate() {
+			fe.Line = lines[fe.Path]
+			errs = append(errs, fe)
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			if errs[i].Line != errs[j].Line {
+				return errs[i].Line < errs[j].Line
+			}
+			return errs[i].Path < errs[j].Path
+		})
+		return nil, &configError{File: filename, Errors: errs}
+	}
+	return cfg, nil
+}
+
+// rateLimits return the rate limits of the endpoint groups
+//
+func (c *jobConfig) rateLimits() map[core.EndpointGroup]core.RateLimit {
+	limits := make(map[core.EndpointGroup]core.RateLimit, len(c.RateLimits))
+	for name, r := range c.RateLimits {
+		limits[core.EndpointGroup(name)] = core.RateLimit{Rate: r.Rate, Burst: r.Burst, MaxInFlight: r.MaxInFlight}
+	}
+	return limits
+}
+
+// validate check the values, return errors with path of the key
+//
+func (c *jobConfig) validate() []fieldError {
+	var errs []fieldError
+	fail := func(path, format string, v ...interface{}) {
+		errs = append(errs, fieldError{Path: path, Msg: fmt.Sprintf(format, v...)})
+	}
+
+	if c.Profile != "" && strings.ContainsAny(c.Profile, `/\:. `) {
+		fail("profile", "must not contain path separators, dots or spaces")
+	}
+	if c.Area != "" {
+		if _, err := core.ResolveArea(c.Area); err != nil {
+			fail("area", "%v, e.g. 1_72_2799_0 or 北京/朝阳区/三环以内", err)
+		}
+	}
+	if c.Lang != "" && c.Lang != "zh-CN" && c.Lang != "en-US" {
+		fail("lang", "must be zh-CN or en-US")
+	}
+	if c.Period < 0 {
+		fail("period", "must be positive")
+	}
+	if c.Workers < 0 {
+		fail("workers", "must be positive")
+	}
+	if c.Retries != nil && *c.Retries < 0 {
+		fail("retries", "must be positive")
+	}
+	if c.Proxy != "" {
+		if _, err := core.ParseProxy(c.Proxy); err != nil {
+			fail("proxy", "%v", err)
+		}
+	}
+	for name, proxy := range c.Proxies {
+		if _, err := core.ParseProxy(proxy); err != nil {
+			fail("proxies."+name, "%v", err)
+		}
+	}
+	for i, proxy := range c.ProxyPool {
+		if _, err := core.ParseProxy(proxy); err != nil {
+			fail(fmt.Sprintf("proxy_pool[%d]", i), "%v", err)
+		}
+	}
+	if c.KeepAlive < 0 {
+		fail("keepalive", "must be positive")
+	}
+
+	if c.Guards.MaxPayment < 0 {
+		fail("guards.max_payment", "must be positive")
+	}
+	if c.Guards.Deadline != "" {
+		t, err := time.Parse(time.RFC3339, c.Guards.Deadline)
+		if err != nil {
+			fail("guards.deadline", "must be RFC3339 time, e.g. 2017-11-11T00:30:00+08:00")
+		}
+		c.deadline = t
+	}
+	if c.Guards.PolicyDeadline != "" {
+		t, err := time.Parse(time.RFC3339, c.Guards.PolicyDeadline)
+		if err != nil {
+			fail("guards.policy_deadline", "must be RFC3339 time, e.g. 2017-11-11T00:05:00+08:00")
+		}
+		c.policyDeadline = t
+	}
+
+	if _, err := parseStart(c.Poll.Start, time.Now()); err != nil {
+		fail("poll.start", "%v", err)
+	}
+	if c.Poll.Window != "" {
+		var err error
+		if c.Poll.window, err = time.ParseDuration(c.Poll.Window); err != nil || c.Poll.window < 0 {
+			fail("poll.window", "must be duration, e.g. 5s")
+		}
+	}
+	switch c.Poll.Strategy {
+	case "", "fixed", "jitter", "backoff":
+	case "burst":
+		if c.Poll.Start == "" {
+			fail("poll.start", "burst needs the start time")
+		}
+	default:
+		fail("poll.strategy", "must be fixed, jitter, backoff or burst")
+	}
+	if c.Poll.MaxErrors < 0 {
+		fail("poll.max_errors", "must be positive")
+	}
+
+	for name, r := range c.RateLimits {
+		path := "rate_limits." + name
+		if !knownKeys[path] {
+			continue // reported as unknown key
+		}
+		if r.Rate < 0 || r.Burst < 0 || r.MaxInFlight < 0 {
+			fail(path, "must be positive")
+		}
+	}
+
+	if _, err := core.ParseOrderPolicy(c.OrderPolicy); err != nil {
+		fail("order_policy", "must be any, all or min-N")
+	}
+
+	policy, err := core.ParseLimitPolicy(c.Limit.Policy)
+	if err != nil {
+		fail("limit.policy", "must be fail, clamp or split")
+	}
+	if policy == core.LimitSplit && len(c.Limit.Profiles) < 2 {
+		fail("limit.profiles", "split needs at least 2 profiles")
+	}
+
+	switch c.Notifier.Type {
+	case "", "desktop":
+	case "webhook":
+		if u, err := url.Parse(c.Notifier.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			fail("notifier.url", "webhook needs a http(s) URL")
+		}
+	default:
+		fail("notifier.type", "must be desktop or webhook")
+	}
+
+	seen := make(map[string]string, len(c.SKUs))
+	checkSKU := func(path string, sku *skuConfig) {
+		if !reSKUID.MatchString(sku.ID) {
+			fail(path+".id", "invalid goods ID %q", sku.ID)
+		} else if prev, ok := seen[sku.ID]; ok {
+			fail(path+".id", "duplicate goods ID %s, already in %s", sku.ID, prev)
+		} else {
+			seen[sku.ID] = path
+		}
+		if sku.Count < 0 || sku.Count > maxGoodsCount {
+			fail(path+".count", "must be between 1 and %d", maxGoodsCount)
+		}
+		if sku.MaxPrice < 0 {
+			fail(path+".max_price", "must be positive")
+		}
+	}
+
+	for i := range c.SKUs {
+		sku := &c.SKUs[i]
+		path := fmt.Sprintf("skus[%d]", i)
+		checkSKU(path, sku)
+		for j := range sku.Alternatives {
+			checkSKU(fmt.Sprintf("%s.alternatives[%d]", path, j), &sku.Alternatives[j])
+		}
+
+		if sku.FallbackAfter != "" {
+			d, err := time.ParseDuration(sku.FallbackAfter)
+			if err != nil || d < 0 {
+				fail(path+".fallback_after", "must be duration, e.g. 30s")
+			}
+			sku.fallback = d
+		}
+	}
+
+	return errs
+}
+
+func decodeYAML(data []byte, cfg *jobConfig) (map[string]int, []fieldError) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, []fieldError{lineError(err)}
+	}
+
+	if err := root.Decode(cfg); err != nil {
+		var te *yaml.TypeError
+		if errors.As(err, &te) {
+			errs := make([]fieldError, 0, len(te.Errors))
+			for _, msg := range te.Errors {
+				errs = append(errs, lineError(errors.New(msg)))
+			}
+			return nil, errs
+		}
+		return nil, []fieldError{lineError(err)}
+	}
+
+	lines := make(map[string]int)
+	yamlLines(&root, "", lines)
+	return lines, nil
+}
+
+func yamlLines(n *yaml.Node, path string, lines map[string]int) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			yamlLines(c, path, lines)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			p := joinPath(path, n.Content[i].Value)
+			lines[p] = n.Content[i].Line
+			yamlLines(n.Content[i+1], p, lines)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			lines[p] = c.Line
+			yamlLines(c, p, lines)
+		}
+	}
+}
+
+func decodeTOML(data []byte, cfg *jobConfig) (map[string]int, []fieldError) {
+	if _, err := toml.Decode(string(data), cfg); err != nil {
+		var pe toml.ParseError
+		if errors.As(err, &pe) {
+			return nil, []fieldError{{Line: pe.Position.Line, Msg: pe.Message}}
+		}
+		return nil, []fieldError{lineError(err)}
+	}
+	return tomlLines(data), nil
+}
+
+// tomlLines locate the keys by scanning the lines, which supports the
+// tables, array of tables and plain keys used by the config. The paths
+// are the same as yamlLines, e.g. skus[1].alternatives[0].id.
+//
+func tomlLines(data []byte) map[string]int {
+	var (
+		table  string
+		lines  = make(map[string]int)
+		arrays = make(map[string]int) // count of each array of tables
+	)
+
+	for i, line := range strings.Split(string(data), "\n") {
+		l := strings.TrimSpace(line)
+		if n := strings.Index(l, "#"); n >= 0 && !strings.ContainsAny(l[:n], `"'`) {
+			l = strings.TrimSpace(l[:n])
+		}
+
+		switch {
+		case l == "":
+		case strings.HasPrefix(l, "[["):
+			keys := strings.Split(strings.Trim(l, "[]"), ".")
+			name := joinPath(tomlTable(keys[:len(keys)-1], arrays), tomlKey(keys[len(keys)-1]))
+			table = fmt.Sprintf("%s[%d]", name, arrays[name])
+			arrays[name]++
+			if _, ok := lines[name]; !ok {
+				lines[name] = i + 1
+			}
+			lines[table] = i + 1
+		case strings.HasPrefix(l, "["):
+			table = tomlTable(strings.Split(strings.Trim(l, "[]"), "."), arrays)
+			lines[table] = i + 1
+		default:
+			if n := strings.Index(l, "="); n > 0 {
+				lines[joinPath(table, tomlKey(l[:n]))] = i + 1
+			}
+		}
+	}
+	return lines
+}
+
+// tomlTable return the path of the table name split by dot, the arrays of
+// tables in it refer to the last element, e.g. skus.alternatives after the
+// 2nd [[skus]] is skus[1].alternatives
+//
+func tomlTable(keys []string, arrays map[string]int) string {
+	var path string
+	for _, key := range keys {
+		path = joinPath(path, tomlKey(key))
+		if n, ok := arrays[path]; ok {
+			path = fmt.Sprintf("%s[%d]", path, n-1)
+		}
+	}
+	return path
+}
+
+func tomlKey(key string) string {
+	return strings.Trim(strings.TrimSpace(key), `"'`)
+}
+
+func decodeJSON(data []byte, cfg *jobConfig) (map[string]int, []fieldError) {
+	if err := json.Unmarshal(data, cfg); err != nil {
+		var (
+			se *json.SyntaxError
+			te *json.UnmarshalTypeError
+		)
+		switch {
+		case errors.As(err, &se):
+			return nil, []fieldError{{Line: offsetLine(data, se.Offset), Msg: se.Error()}}
+		case errors.As(err, &te):
+			return nil, []fieldError{{
+				Line: offsetLine(data, te.Offset),
+				Path: jsonPath(te.Field),
+				Msg:  fmt.Sprintf("cannot use %s as %s", te.Value, te.Type),
+			}}
+		}
+		return nil, []fieldError{{Msg: err.Error()}}
+	}
+
+	lines := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	jsonLines(dec, data, "", lines)
+	return lines, nil
+}
+
+// jsonLines walk the tokens and record the line of each key
+//
+func jsonLines(dec *json.Decoder, data []byte, path string, lines map[string]int) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			off := dec.InputOffset()
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			p := joinPath(path, fmt.Sprint(key))
+			lines[p] = offsetLine(data, off)
+			if err = jsonLines(dec, data, p, lines); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			lines[p] = offsetLine(data, dec.InputOffset())
+			if err = jsonLines(dec, data, p, lines); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+// jsonPath convert the field of json.UnmarshalTypeError to the path of
+// the other formats, e.g. skus.0.count to skus[0].count
+//
+func jsonPath(field string) string {
+	var path string
+	for _, key := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(key); err == nil && path != "" {
+			path = fmt.Sprintf("%s[%s]", path, key)
+		} else {
+			path = joinPath(path, key)
+		}
+	}
+	return path
+}
+
+// offsetLine return the line number of the first token after offset
+//
+func offsetLine(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// lineError extract the "line N" from the error message of yaml / toml
+//
+func lineError(err error) fieldError {
+	fe := fieldError{Msg: err.Error()}
+	if m := reLineNum.FindStringSubmatch(fe.Msg); m != nil {
+		fe.Line, _ = strconv.Atoi(m[1])
+		fe.Msg = strings.TrimSpace(strings.TrimPrefix(strings.SplitN(fe.Msg, m[0], 2)[1], ":"))
+	}
+	return fe
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
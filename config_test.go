@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadConfigErrors write the config to a file of ext and return the errors
+//
+func loadConfigErrors(t *testing.T, ext, data string) []fieldError {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "autobuy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "job"+ext)
+	if err := ioutil.WriteFile(filename, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loadConfig(filename)
+	if err == nil {
+		return nil
+	}
+	var ce *configError
+	if !errors.As(err, &ce) {
+		t.Fatalf("loadConfig: %v, want configError", err)
+	}
+	return ce.Errors
+}
+
+func TestLoadConfigLines(t *testing.T) {
+	tests := []struct {
+		name string
+		ext  string
+		data string
+		line int
+		path string
+	}{
+		{
+			name: "yaml negative count",
+			ext:  ".yaml",
+			data: "skus:\n  - id: \"100\"\n    count: 1\n  - id: \"200\"\n    count: -1\n",
+			line: 5,
+			path: "skus[1].count",
+		},
+		{
+			name: "yaml nested alternative",
+			ext:  ".yaml",
+			data: "skus:\n  - id: \"100\"\n    alternatives:\n      - id: abc\n",
+			line: 4,
+			path: "skus[0].alternatives[0].id",
+		},
+		{
+			name: "yaml unknown key",
+			ext:  ".yml",
+			data: "period: 500\nguards:\n  max_pay: 10\n",
+			line: 3,
+			path: "guards.max_pay",
+		},
+		{
+			name: "toml negative count",
+			ext:  ".toml",
+			data: "[[skus]]\nid = \"100\"\n\n[[skus]]\nid = \"200\"\ncount = -1\n",
+			line: 6,
+			path: "skus[1].count",
+		},
+		{
+			name: "toml nested array of tables",
+			ext:  ".toml",
+			data: "[[skus]]\nid = \"100\"\n\n[[skus.alternatives]]\nid = \"101\"\n\n" +
+				"[[skus]]\nid = \"200\"\n\n[[skus.alternatives]]\nid = \"201\"\n\n[[skus.alternatives]]\nid = \"abc\"\n",
+			line: 14,
+			path: "skus[1].alternatives[1].id",
+		},
+		{
+			name: "toml comment",
+			ext:  ".toml",
+			data: "# period: 100\nperiod = -1 # ms\n",
+			line: 2,
+			path: "period",
+		},
+		{
+			name: "json negative count",
+			ext:  ".json",
+			data: "{\n  \"skus\": [\n    {\"id\": \"100\"},\n    {\"id\": \"200\",\n     \"count\": -1}\n  ]\n}\n",
+			line: 5,
+			path: "skus[1].count",
+		},
+		{
+			name: "json type error",
+			ext:  ".json",
+			data: "{\n  \"skus\": [\n    {\"id\": \"100\", \"count\": 1},\n    {\"id\": \"200\",\n     \"count\": \"2\"}\n  ]\n}\n",
+			line: 5,
+			path: "skus[1].count",
+		},
+		{
+			name: "json nested type error",
+			ext:  ".json",
+			data: "{\"skus\": [{\"id\": \"100\",\n  \"alternatives\": [{\"id\": \"101\",\n    \"max_price\": \"9.9\"}]}]}\n",
+			line: 3,
+			path: "skus[0].alternatives[0].max_price",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := loadConfigErrors(t, tt.ext, tt.data)
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want 1 error", errs)
+			}
+			if errs[0].Line != tt.line || errs[0].Path != tt.path {
+				t.Errorf("error at %d %s (%s), want %d %s", errs[0].Line, errs[0].Path, errs[0].Msg, tt.line, tt.path)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSyntaxError(t *testing.T) {
+	tests := []struct {
+		name string
+		ext  string
+		data string
+		line int
+	}{
+		{"yaml", ".yaml", "period: 100\nskus:\n  - id: \"1\n", 3},
+		{"toml", ".toml", "period = 100\nworkers = = 2\n", 2},
+		{"json", ".json", "{\n  \"period\": 100,\n  \"skus\": [,]\n}\n", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := loadConfigErrors(t, tt.ext, tt.data)
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want 1 error", errs)
+			}
+			if errs[0].Line != tt.line {
+				t.Errorf("error at line %d (%s), want %d", errs[0].Line, errs[0].Msg, tt.line)
+			}
+		})
+	}
+}
+
+func TestLoadConfigUnknownFormat(t *testing.T) {
+	if _, err := loadConfig("job.ini"); err == nil {
+		t.Error("loadConfig(job.ini) succeeded, want error")
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"period":                    "period",
+		"skus.0.count":              "skus[0].count",
+		"skus.1.alternatives.12.id": "skus[1].alternatives[12].id",
+	}
+	for field, want := range tests {
+		if got := jsonPath(field); got != want {
+			t.Errorf("jsonPath(%q) = %q, want %q", field, got, want)
+		}
+	}
+}
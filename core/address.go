@@ -0,0 +1,215 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	sjson "github.com/bitly/go-simplejson"
+)
+
+const (
+	URLConsigneeList = "https://trade.jd.com/shopping/dynamic/consignee/consigneeList.action"
+	URLSaveConsignee = "https://trade.jd.com/shopping/dynamic/consignee/saveConsignee.action"
+)
+
+// Address is a consignee address saved in the account
+//
+type Address struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Mobile   string `json:"mobile"`
+	Detail   string `json:"detail"`
+	Area     string `json:"area"`     // ship area code, e.g. 1_72_2799_0
+	Selected bool   `json:"selected"` // used by the order
+}
+
+// Addresses list the consignee addresses on the checkout page
+//
+//  <li id="consignee_index_138000000">
+//    <div class="consignee-item item-selected" consigneeId="138000000"
+//      provinceId="1" cityId="72" areaId="2799" townId="0">...</div>
+//    <div class="addr-detail">
+//      <span class="addr-name">...</span>
+//      <span class="addr-info">北京 朝阳区 三环以内 ...</span>
+//      <span class="addr-tel">138****0000</span>
+//    </div>
+//  </li>
+//
+func (jd *JingDong) Addresses() ([]*Address, error) {
+	data, err := jd.getResponse("GET", URLConsigneeList, func(URL string) string {
+		u, _ := url.Parse(URL)
+		q := u.Query()
+		q.Set("r", strconv.FormatInt(time.Now().Unix()*1000, 10))
+		u.RawQuery = q.Encode()
+		return u.String()
+	})
+
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgAddressFailed), err)
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewBuffer(data))
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgAddressParseFailed), err)
+		return nil, newParseError("address", data, err)
+	}
+
+	var addrs []*Address
+	doc.Find("li[id^='consignee_index_']").Each(func(i int, s *goquery.Selection) {
+		item := s.Find("div.consignee-item").Eq(0)
+		addr := &Address{
+			ID:       item.AttrOr("consigneeid", strings.TrimPrefix(s.AttrOr("id", ""), "consignee_index_")),
+			Name:     strings.TrimSpace(s.Find("span.addr-name").Eq(0).Text()),
+			Mobile:   strings.TrimSpace(s.Find("span.addr-tel").Eq(0).Text()),
+			Detail:   strings.TrimSpace(s.Find("span.addr-info").Eq(0).Text()),
+			Selected: item.HasClass("item-selected"),
+		}
+		addr.Area = addressArea(item, addr.Detail)
+		addrs = append(addrs, addr)
+	})
+
+	if len(addrs) == 0 && doc.Find("div.consignee-item").Length() > 0 {
+		return nil, newParseError("address", data, nil)
+	}
+	return addrs, nil
+}
+
+// addressArea return the area code from the attributes of consignee item,
+// or resolve it from the leading names of address detail. It returns empty
+// if the city is unknown, JD does not accept the province only.
+//
+func addressArea(item *goquery.Selection, detail string) string {
+	var codes []string
+	for _, attr := range []string{"provinceid", "cityid", "areaid", "townid"} {
+		code := item.AttrOr(attr, "")
+		if code == "" {
+			code = "0"
+		}
+		codes = append(codes, code)
+	}
+	if area := strings.Join(codes, "_"); codes[0] != "0" && codes[1] != "0" && CheckArea(area) == nil {
+		return area
+	}
+
+	db, err := DefaultAreaDB()
+	if err != nil {
+		return ""
+	}
+
+	var area *Area
+	names := strings.Fields(detail)
+	for n := 1; n <= len(names) && n <= areaLevels; n++ {
+		a, err := db.Resolve(strings.Join(names[:n], "/"))
+		if err != nil {
+			break
+		}
+		area = a
+	}
+	if area == nil || area.Parent == nil {
+		return ""
+	}
+	return area.FullCode()
+}
+
+// FindAddress return the address by ID, or the only address whose name,
+// mobile or detail contains the query
+//
+func FindAddress(addrs []*Address, query string) (*Address, error) {
+	query = strings.TrimSpace(query)
+	for _, addr := range addrs {
+		if addr.ID == query {
+			return addr, nil
+		}
+	}
+
+	var found []*Address
+	q := strings.ToLower(query)
+	for _, addr := range addrs {
+		if strings.Contains(strings.ToLower(addr.Name), q) ||
+			strings.Contains(addr.Mobile, q) ||
+			strings.Contains(strings.ToLower(addr.Detail), q) {
+			found = append(found, addr)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: %q", ErrAddressNotFound, query)
+	case 1:
+		return found[0], nil
+	}
+
+	ids := make([]string, 0, len(found))
+	for _, addr := range found {
+		ids = append(ids, addr.ID)
+	}
+	return nil, fmt.Errorf("%w: %q matches %d addresses (%s), use the ID",
+		ErrAddressNotFound, query, len(found), strings.Join(ids, ", "))
+}
+
+// SelectAddress choose the consignee address of the order by ID or fuzzy
+// match, the ShipArea is changed to the area of the address so that the
+// stock is checked where the order ships.
+// It must not be called while buying goods.
+//
+func (jd *JingDong) SelectAddress(query string) (*Address, error) {
+	addrs, err := jd.Addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := FindAddress(addrs, query)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgAddressNotFound), err)
+		return nil, err
+	}
+
+	if !addr.Selected {
+		data, err := jd.getResponse("POST", URLSaveConsignee, func(URL string) string {
+			u, _ := url.Parse(URL)
+			q := u.Query()
+			q.Set("consigneeParam.newId", addr.ID)
+			q.Set("consigneeParam.type", "null")
+			q.Set("consigneeParam.commonConsigneeSize", strconv.Itoa(len(addrs)))
+			q.Set("consigneeParam.isUpdateCommonAddress", "0")
+			q.Set("consigneeParam.isSelfPick", "0")
+			q.Set("consigneeParam.pickType", "0")
+			u.RawQuery = q.Encode()
+			return u.String()
+		})
+
+		if err != nil {
+			jd.Logger.Error(jd.msg(msgAddressSelectFailed), addr.ID, err)
+			return nil, err
+		}
+
+		// the response is the html of order info, or json when failed
+		if js, e := sjson.NewJson(data); e == nil {
+			if succ, ok := js.CheckGet("success"); ok {
+				if b, _ := succ.Bool(); !b {
+					msg, _ := js.Get("message").String()
+					jd.Logger.Error(jd.msg(msgAddressSelectFailed), addr.ID, msg)
+					return nil, fmt.Errorf("select address %s: %s", addr.ID, msg)
+				}
+			}
+		}
+
+		for _, a := range addrs {
+			a.Selected = a == addr
+		}
+	}
+
+	if addr.Area != "" {
+		jd.ShipArea = addr.Area
+	} else {
+		jd.Logger.Warn(jd.msg(msgAddressNoArea), addr.Detail, jd.ShipArea)
+	}
+	jd.Logger.Info(jd.msg(msgAddressSelected), addr.Name, addr.Detail, jd.ShipArea)
+	return addr, nil
+}
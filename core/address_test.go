@@ -0,0 +1,34 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestAddressArea(t *testing.T) {
+	tests := []struct {
+		name   string
+		attrs  string
+		detail string
+		want   string
+	}{
+		{"attributes", `provinceId="1" cityId="72" areaId="2799" townId="0"`, "", "1_72_2799_0"},
+		{"attributes without city", `provinceId="22" cityId="0"`, "", ""},
+		{"names", "", "北京 朝阳区 三环以内 某某路1号", "1_72_2799_0"},
+		{"names of city", "", "广东 广州市 天河区", "19_1601_0_0"},
+		{"province only", "", "四川 成都市 武侯区", ""},
+		{"unknown", "", "某某路1号", ""},
+	}
+
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div class="consignee-item" ` + tt.attrs + `></div>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := addressArea(doc.Find("div.consignee-item"), tt.detail); got != tt.want {
+			t.Errorf("%s: addressArea = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
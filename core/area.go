@@ -0,0 +1,320 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed areas.tsv
+var areaData []byte
+
+// areaLevels is the count of levels in area code, e.g. 1_72_2799_0
+//
+const areaLevels = 4
+
+var (
+	reAreaCode = regexp.MustCompile(`^\d+(_\d+){2,3}$`)
+
+	// reAreaPrefix match the area code given by user, the levels omitted
+	// are 0, e.g. 1_72
+	reAreaPrefix = regexp.MustCompile(`^\d+(_\d+){1,3}$`)
+)
+
+// Area is a province, city, district or town of ship area
+//
+type Area struct {
+	Code     string  `json:"code"`
+	Name     string  `json:"name"`
+	Pinyin   string  `json:"pinyin,omitempty"`
+	Parent   *Area   `json:"-"`
+	Children []*Area `json:"-"`
+}
+
+// Path return the areas from province to a
+//
+func (a *Area) Path() []*Area {
+	var path []*Area
+	for p := a; p != nil; p = p.Parent {
+		path = append([]*Area{p}, path...)
+	}
+	return path
+}
+
+// FullCode return the area code used by JD, e.g. 1_72_2799_0
+//
+func (a *Area) FullCode() string {
+	codes := make([]string, 0, areaLevels)
+	for _, p := range a.Path() {
+		codes = append(codes, p.Code)
+	}
+	for len(codes) < areaLevels {
+		codes = append(codes, "0")
+	}
+	return strings.Join(codes, "_")
+}
+
+// FullName return the names from province to a, e.g. 北京/朝阳区/三环以内
+//
+func (a *Area) FullName() string {
+	var names []string
+	for _, p := range a.Path() {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, "/")
+}
+
+// match report whether name is the name or pinyin of a, the suffix
+// like 省, 市, 区 and 县 can be omitted.
+//
+func (a *Area) match(name string) bool {
+	name = strings.ToLower(strings.NewReplacer(" ", "", "-", "", "'", "").Replace(name))
+	if name == "" {
+		return false
+	}
+	if name == a.Name || name == a.Pinyin {
+		return true
+	}
+	for _, suffix := range []string{"省", "市", "新区", "区", "县"} {
+		if strings.TrimSuffix(a.Name, suffix) == strings.TrimSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AreaDB is the dataset of ship areas
+//
+type AreaDB struct {
+	roots   []*Area
+	codes   map[string]*Area
+	partial bool // the embedded dataset
+}
+
+// LoadAreaDB read the area dataset, each line is tab separated
+// `code parent name pinyin`, the parent of province is 0. Empty lines
+// and lines start with # are ignored.
+//
+func LoadAreaDB(r io.Reader) (*AreaDB, error) {
+	db := &AreaDB{codes: make(map[string]*Area)}
+	parents := make(map[*Area]string)
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("area dataset line %d: want `code parent name [pinyin]`", n)
+		}
+		a := &Area{Code: fields[0], Name: fields[2]}
+		if len(fields) > 3 {
+			a.Pinyin = strings.ToLower(fields[3])
+		}
+		if _, ok := db.codes[a.Code]; ok {
+			return nil, fmt.Errorf("area dataset line %d: duplicate code %s", n, a.Code)
+		}
+		db.codes[a.Code] = a
+		parents[a] = fields[1]
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, a := range db.all() {
+		code := parents[a]
+		if code == "0" {
+			db.roots = append(db.roots, a)
+			continue
+		}
+		p, ok := db.codes[code]
+		if !ok {
+			return nil, fmt.Errorf("area dataset: parent %s of %s not found", code, a.Code)
+		}
+		a.Parent = p
+		p.Children = append(p.Children, a)
+	}
+	return db, nil
+}
+
+var (
+	areaMu sync.Mutex
+	areaDB *AreaDB
+)
+
+// DefaultAreaDB return the area dataset embedded in the package, or the
+// one set by SetAreaDB
+//
+func DefaultAreaDB() (*AreaDB, error) {
+	areaMu.Lock()
+	defer areaMu.Unlock()
+
+	if areaDB == nil {
+		db, err := LoadAreaDB(bytes.NewReader(areaData))
+		if err != nil {
+			return nil, err
+		}
+		db.partial = true
+		areaDB = db
+	}
+	return areaDB, nil
+}
+
+// SetAreaDB replace the default area dataset, e.g. with an updated one
+//
+func SetAreaDB(db *AreaDB) {
+	areaMu.Lock()
+	areaDB = db
+	areaMu.Unlock()
+}
+
+// all return the areas ordered by code
+//
+func (db *AreaDB) all() []*Area {
+	var areas []*Area
+	for _, a := range db.codes {
+		areas = append(areas, a)
+	}
+	sortAreas(areas)
+	return areas
+}
+
+// Resolve return the area by names separated by /, e.g.
+// 北京/朝阳区/三环以内 or beijing/chaoyang/sanhuanyinei
+//
+func (db *AreaDB) Resolve(names string) (*Area, error) {
+	var (
+		area     *Area
+		children = db.roots
+	)
+
+	for _, name := range strings.Split(strings.Trim(names, "/ "), "/") {
+		var found *Area
+		for _, c := range children {
+			if c.match(strings.TrimSpace(name)) {
+				found = c
+				break
+			}
+		}
+		if found == nil {
+			var hint string
+			if db.partial {
+				hint = ", the embedded area dataset is partial, use the area code instead"
+			}
+			if area == nil {
+				return nil, fmt.Errorf("%w: %q not found%s", ErrInvalidArea, name, hint)
+			}
+			return nil, fmt.Errorf("%w: %q not found in %s%s", ErrInvalidArea, name, area.FullName(), hint)
+		}
+		area, children = found, found.Children
+	}
+	return area, nil
+}
+
+// Lookup return the area by code, e.g. 1_72_2799_0 or 2799
+//
+func (db *AreaDB) Lookup(code string) (*Area, error) {
+	codes := strings.Split(code, "_")
+	last := codes[0]
+	for _, c := range codes {
+		if c != "0" {
+			last = c
+		}
+	}
+
+	a, ok := db.codes[last]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s not found", ErrInvalidArea, code)
+	}
+	for len(codes) > 1 && len(codes) < areaLevels {
+		codes = append(codes, "0")
+	}
+	if len(codes) > 1 && strings.Join(codes, "_") != a.FullCode() {
+		return nil, fmt.Errorf("%w: %s, the code of %s is %s", ErrInvalidArea, code, a.FullName(), a.FullCode())
+	}
+	return a, nil
+}
+
+// Search return the areas whose name or pinyin contains keyword
+//
+func (db *AreaDB) Search(keyword string) []*Area {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return nil
+	}
+
+	var areas []*Area
+	for _, a := range db.all() {
+		if strings.Contains(a.Name, keyword) || (a.Pinyin != "" && strings.Contains(a.Pinyin, keyword)) {
+			areas = append(areas, a)
+		}
+	}
+	return areas
+}
+
+// ResolveArea return the area code of s by the default dataset, s can be
+// an area code like 1_72 or names like 北京/朝阳区/三环以内. The names must
+// go down to the last level in the dataset, JD does not accept the
+// province only and the area is never chosen for the user.
+//
+func ResolveArea(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if reAreaPrefix.MatchString(s) {
+		codes := strings.Split(s, "_")
+		for len(codes) < areaLevels {
+			codes = append(codes, "0")
+		}
+		if codes[1] == "0" {
+			return "", fmt.Errorf("%w: %q, the city is required", ErrInvalidArea, s)
+		}
+		return strings.Join(codes, "_"), nil
+	}
+
+	db, err := DefaultAreaDB()
+	if err != nil {
+		return "", err
+	}
+	a, err := db.Resolve(s)
+	if err != nil {
+		return "", err
+	}
+	if len(a.Children) > 0 {
+		var names []string
+		for _, c := range a.Children {
+			names = append(names, c.Name)
+		}
+		return "", fmt.Errorf("%w: %q is not complete, the next level is one of %s",
+			ErrInvalidArea, s, strings.Join(names, ", "))
+	}
+	if a.Parent == nil {
+		return "", fmt.Errorf("%w: %q, the cities of %s are not in the area dataset", ErrInvalidArea, s, a.Name)
+	}
+	return a.FullCode(), nil
+}
+
+// CheckArea validate the format of area code, e.g. 1_72_2799_0
+//
+func CheckArea(code string) error {
+	if !reAreaCode.MatchString(code) {
+		return fmt.Errorf("%w: %q, want code like 1_72_2799_0", ErrInvalidArea, code)
+	}
+	return nil
+}
+
+func sortAreas(areas []*Area) {
+	sort.Slice(areas, func(i, j int) bool {
+		if len(areas[i].Code) != len(areas[j].Code) {
+			return len(areas[i].Code) < len(areas[j].Code)
+		}
+		return areas[i].Code < areas[j].Code
+	})
+}
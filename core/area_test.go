@@ -0,0 +1,167 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testAreas = `# code	parent	name	pinyin
+1	0	北京	beijing
+19	0	广东	guangdong
+26	0	西藏	xizang
+
+72	1	朝阳区	chaoyang
+2800	1	海淀区	haidian
+2799	72	三环以内	sanhuanyinei
+1601	19	广州市	guangzhou
+`
+
+func testAreaDB(t *testing.T) *AreaDB {
+	t.Helper()
+
+	db, err := LoadAreaDB(strings.NewReader(testAreas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLoadAreaDB(t *testing.T) {
+	db := testAreaDB(t)
+	if len(db.roots) != 3 || len(db.codes) != 7 {
+		t.Errorf("LoadAreaDB = %d roots and %d areas, want 3 and 7", len(db.roots), len(db.codes))
+	}
+	if a := db.codes["2799"]; a.Parent != db.codes["72"] || a.Pinyin != "sanhuanyinei" {
+		t.Errorf("area 2799 = %+v, want the child of 72", a)
+	}
+
+	for _, data := range []string{
+		"1\t0",
+		"1\t0\t北京\n1\t0\t上海",
+		"72\t1\t朝阳区",
+	} {
+		if _, err := LoadAreaDB(strings.NewReader(data)); err == nil {
+			t.Errorf("LoadAreaDB(%q) succeeded, want error", data)
+		}
+	}
+
+	// the embedded dataset
+	if _, err := LoadAreaDB(strings.NewReader(string(areaData))); err != nil {
+		t.Errorf("LoadAreaDB(areas.tsv): %v", err)
+	}
+}
+
+func TestAreaResolve(t *testing.T) {
+	db := testAreaDB(t)
+	tests := []struct {
+		names string
+		want  string
+	}{
+		{"北京/朝阳区/三环以内", "1_72_2799_0"},
+		{"beijing/chaoyang/sanhuanyinei", "1_72_2799_0"},
+		{" /北京市/朝阳/三环以内/ ", "1_72_2799_0"},
+		{"BeiJing/HaiDian", "1_2800_0_0"},
+		{"北京", "1_0_0_0"},
+		{"广东省/广州", "19_1601_0_0"},
+	}
+	for _, tt := range tests {
+		a, err := db.Resolve(tt.names)
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", tt.names, err)
+			continue
+		}
+		if got := a.FullCode(); got != tt.want {
+			t.Errorf("Resolve(%q) = %s, want %s", tt.names, got, tt.want)
+		}
+	}
+
+	for _, names := range []string{"", "上海", "北京/浦东新区", "北京/朝阳区/三环以内/x"} {
+		if a, err := db.Resolve(names); !errors.Is(err, ErrInvalidArea) {
+			t.Errorf("Resolve(%q) = %v, %v, want ErrInvalidArea", names, a, err)
+		}
+	}
+}
+
+func TestAreaLookup(t *testing.T) {
+	db := testAreaDB(t)
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"2799", "北京/朝阳区/三环以内"},
+		{"1_72_2799_0", "北京/朝阳区/三环以内"},
+		{"1_72", "北京/朝阳区"},
+		{"1", "北京"},
+	}
+	for _, tt := range tests {
+		a, err := db.Lookup(tt.code)
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", tt.code, err)
+			continue
+		}
+		if got := a.FullName(); got != tt.want {
+			t.Errorf("Lookup(%q) = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+
+	for _, code := range []string{"3", "19_72", "1_72_2800_0", ""} {
+		if a, err := db.Lookup(code); !errors.Is(err, ErrInvalidArea) {
+			t.Errorf("Lookup(%q) = %v, %v, want ErrInvalidArea", code, a, err)
+		}
+	}
+}
+
+func TestResolveArea(t *testing.T) {
+	SetAreaDB(testAreaDB(t))
+	defer SetAreaDB(nil)
+
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"1_72_2799_0", "1_72_2799_0"},
+		{"1_72", "1_72_0_0"},
+		{" 1_72_2799 ", "1_72_2799_0"},
+		{"北京/朝阳区/三环以内", "1_72_2799_0"},
+		{"beijing/haidian", "1_2800_0_0"},
+		{"广东/广州市", "19_1601_0_0"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveArea(tt.s)
+		if err != nil {
+			t.Errorf("ResolveArea(%q): %v", tt.s, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResolveArea(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{
+		"1_0",           // no city
+		"北京",            // incomplete
+		"北京/朝阳区",        // incomplete
+		"广东",            // incomplete
+		"西藏",            // no city in dataset
+		"上海/浦东新区",       // not in dataset
+		"1_72_2799_0_1", // too many levels
+	} {
+		if got, err := ResolveArea(s); !errors.Is(err, ErrInvalidArea) {
+			t.Errorf("ResolveArea(%q) = %q, %v, want ErrInvalidArea", s, got, err)
+		}
+	}
+}
+
+func TestCheckArea(t *testing.T) {
+	for _, code := range []string{"1_72_2799_0", "1_72_2799"} {
+		if err := CheckArea(code); err != nil {
+			t.Errorf("CheckArea(%q): %v", code, err)
+		}
+	}
+	for _, code := range []string{"", "1", "1_72", "1_72_2799_0_0", "1_72_x_0", "北京"} {
+		if err := CheckArea(code); !errors.Is(err, ErrInvalidArea) {
+			t.Errorf("CheckArea(%q) = %v, want ErrInvalidArea", code, err)
+		}
+	}
+}
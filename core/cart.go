@@ -0,0 +1,344 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	sjson "github.com/bitly/go-simplejson"
+)
+
+const (
+	URLSelectItem    = "https://cart.jd.com/selectItem.action"
+	URLCancelItem    = "https://cart.jd.com/cancelItem.action"
+	URLSelectAllItem = "https://cart.jd.com/selectAllItem.action"
+	URLCancelAllItem = "https://cart.jd.com/cancelAllItem.action"
+	URLRemoveItems   = "https://cart.jd.com/batchRemoveSkusFromCart.action"
+)
+
+// CartItem is a goods in the shopping cart
+//
+type CartItem struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Price   string `json:"price"`
+	Total   string `json:"total"`
+	Count   int    `json:"count"`
+	Checked bool   `json:"checked"` // selected to be ordered
+
+	VenderID string `json:"vender_id,omitempty"`
+	PromoID  string `json:"promo_id,omitempty"`
+	TargetID string `json:"target_id,omitempty"`
+}
+
+// CountResult is the count of goods in cart after changed
+//
+type CountResult struct {
+	ID        string `json:"id"`
+	Requested int    `json:"requested"`
+	Count     int    `json:"count"` // accepted by JD
+}
+
+// Cart is the shopping cart
+//
+type Cart struct {
+	Items []*CartItem `json:"items"`
+	Count string      `json:"count"` // count of selected goods
+	Total string      `json:"total"` // total price of selected goods
+}
+
+// Item return the cart item by sku ID, nil if not in cart
+//
+func (c *Cart) Item(ID string) *CartItem {
+	for _, item := range c.Items {
+		if item.ID == ID {
+			return item
+		}
+	}
+	return nil
+}
+
+// CartDetails get the shopping cart details
+//
+func (jd *JingDong) CartDetails() (*Cart, error) {
+	jd.Logger.Info(strSeperater)
+	jd.Logger.Info(jd.msg(msgCartTitle))
+
+	var (
+		err  error
+		req  *http.Request
+		resp *http.Response
+		doc  *goquery.Document
+	)
+
+	if req, err = http.NewRequest("GET", URLCartInfo, nil); err != nil {
+		jd.Logger.Error(jd.msg(msgRequestFailed), URLCartInfo, err)
+		return nil, err
+	}
+
+	if resp, err = jd.client.Do(req); err != nil {
+		jd.Logger.Error(jd.msg(msgCartFailed), err)
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	data, err := responseData(resp)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgReadRespFailed), err)
+		return nil, err
+	}
+	if doc, err = goquery.NewDocumentFromReader(bytes.NewReader(data)); err != nil {
+		jd.Logger.Error(jd.msg(msgCartParseFailed), err)
+		return nil, newParseError("cart", data, err)
+	}
+
+	jd.Logger.Info(jd.msg(msgCartHeader))
+	cartFormat := "%-6s%-6s%-10s%-10s%-12s%s"
+
+	cart := &Cart{}
+	doc.Find("div.item-form").Each(func(i int, p *goquery.Selection) {
+		item := &CartItem{}
+
+		check := " -"
+		checkTag := p.Find("div.cart-checkbox input").Eq(0)
+		if _, exist := checkTag.Attr("checked"); exist {
+			check = " +"
+			item.Checked = true
+		}
+
+		count := "0"
+		countTag := p.Find("div.quantity-form input").Eq(0)
+		if val, exist := countTag.Attr("value"); exist {
+			count = val
+		}
+		item.Count, _ = strconv.Atoi(count)
+
+		hrefTag := p.Find("div.p-img a").Eq(0)
+		if href, exist := hrefTag.Attr("href"); exist {
+			// http://item.jd.com/2967929.html
+			pos1 := strings.LastIndex(href, "/")
+			pos2 := strings.LastIndex(href, ".")
+			if pos1 < pos2 {
+				item.ID = href[pos1+1 : pos2]
+			}
+		}
+
+		// <div class="item-item" venderid="1000000127" promoid="0" targetid="0">
+		item.VenderID = closestAttr(p, "venderid")
+		item.PromoID = closestAttr(p, "promoid")
+		item.TargetID = closestAttr(p, "targetid")
+
+		item.Price = strings.Trim(p.Find("div.p-price strong").Eq(0).Text(), " ")
+		item.Total = strings.Trim(p.Find("div.p-sum strong").Eq(0).Text(), " ")
+		item.Name = strings.Trim(p.Find("div.p-name a").Eq(0).Text(), " \n\t")
+		jd.Logger.Info(cartFormat, check, count, item.Price, item.Total, item.ID, truncate(item.Name))
+
+		cart.Items = append(cart.Items, item)
+	})
+
+	cart.Count = strings.Trim(doc.Find("div.amount-sum em").Eq(0).Text(), " ")
+	cart.Total = strings.Trim(doc.Find("span.sumPrice em").Eq(0).Text(), " ")
+	jd.Logger.Info(jd.msg(msgCartTotalCount), cart.Count)
+	jd.Logger.Info(jd.msg(msgCartTotalValue), cart.Total)
+
+	return cart, nil
+}
+
+// closestAttr return the attribute of s or its nearest ancestor
+//
+func closestAttr(s *goquery.Selection, attr string) string {
+	for ; s.Length() > 0; s = s.Parent() {
+		if v, exist := s.Attr(attr); exist {
+			return v
+		}
+	}
+	return ""
+}
+
+// cartAction post the cart operation, the response is the json of cart
+// or {"success":false,...} when failed
+//
+func (jd *JingDong) cartAction(URL string, params map[string]string) error {
+	if err := CheckArea(jd.ShipArea); err != nil {
+		return err
+	}
+
+	data, err := jd.getResponse("POST", URL, func(URL string) string {
+		u, _ := url.Parse(URL)
+		q := u.Query()
+		for k, v := range params {
+			q.Set(k, v)
+		}
+		q.Set("outSkus", "")
+		q.Set("random", strconv.FormatFloat(rand.Float64(), 'f', 16, 64))
+		q.Set("locationId", jd.ShipArea)
+		u.RawQuery = q.Encode()
+		return u.String()
+	})
+	if err != nil {
+		return err
+	}
+
+	js, err := sjson.NewJson(data)
+	if err != nil {
+		return newParseError("cart", data, err)
+	}
+	if succ, ok := js.CheckGet("success"); ok {
+		if b, _ := succ.Bool(); !b {
+			msg, _ := js.Get("message").String()
+			return fmt.Errorf("%s: %s", URL, msg)
+		}
+	}
+	return nil
+}
+
+// itemAction select or unselect the goods in cart one by one, the target
+// and promotion ID are required by the goods of promotion bundles
+//
+func (jd *JingDong) itemAction(URL string, items []*CartItem) error {
+	for _, item := range items {
+		err := jd.cartAction(URL, map[string]string{
+			"pid":      item.ID,
+			"ptype":    "1",
+			"packId":   "0",
+			"targetId": orDefault(item.TargetID, "0"),
+			"promoID":  orDefault(item.PromoID, "0"),
+		})
+		if err != nil {
+			jd.Logger.Error(jd.msg(msgCartActionFailed), item.ID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// cartItems return the goods of IDs in cart
+//
+func (jd *JingDong) cartItems(IDs []string) ([]*CartItem, error) {
+	if len(IDs) == 0 {
+		return nil, nil
+	}
+
+	cart, err := jd.CartDetails()
+	if err != nil {
+		return nil, err
+	}
+	return cart.items(IDs)
+}
+
+// items return the goods of IDs, ErrNotInCart if any is not in cart
+//
+func (c *Cart) items(IDs []string) ([]*CartItem, error) {
+	items := make([]*CartItem, 0, len(IDs))
+	for _, ID := range IDs {
+		item := c.Item(ID)
+		if item == nil {
+			return nil, &ItemError{ID: ID, Err: ErrNotInCart}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// SelectItems check the goods in cart, so they are ordered
+//
+func (jd *JingDong) SelectItems(IDs ...string) error {
+	items, err := jd.cartItems(IDs)
+	if err != nil {
+		return err
+	}
+	return jd.itemAction(URLSelectItem, items)
+}
+
+// UnselectItems uncheck the goods in cart, so they are not ordered
+//
+func (jd *JingDong) UnselectItems(IDs ...string) error {
+	items, err := jd.cartItems(IDs)
+	if err != nil {
+		return err
+	}
+	return jd.itemAction(URLCancelItem, items)
+}
+
+// SelectAll check all the goods in cart
+//
+func (jd *JingDong) SelectAll() error {
+	if err := jd.cartAction(URLSelectAllItem, nil); err != nil {
+		jd.Logger.Error(jd.msg(msgCartActionFailed), "*", err)
+		return err
+	}
+	return nil
+}
+
+// SelectOnly check the goods and uncheck all the others in cart, so that
+// the order only contains the goods. Empty IDs unchecks all.
+//
+func (jd *JingDong) SelectOnly(IDs ...string) error {
+	items, err := jd.cartItems(IDs)
+	if err != nil {
+		return err
+	}
+	return jd.selectOnly(items)
+}
+
+func (jd *JingDong) selectOnly(items []*CartItem) error {
+	if err := jd.cartAction(URLCancelAllItem, nil); err != nil {
+		jd.Logger.Error(jd.msg(msgCartActionFailed), "*", err)
+		return err
+	}
+	return jd.itemAction(URLSelectItem, items)
+}
+
+// RemoveFromCart remove the goods from cart, the selection of the other
+// goods is kept
+//
+func (jd *JingDong) RemoveFromCart(IDs ...string) error {
+	cart, err := jd.CartDetails()
+	if err != nil {
+		return err
+	}
+
+	items, err := cart.items(IDs)
+	if err != nil {
+		return err
+	}
+	remove := make(map[string]bool, len(items))
+	for _, item := range items {
+		remove[item.ID] = true
+	}
+
+	var selected []*CartItem
+	for _, item := range cart.Items {
+		if item.Checked && !remove[item.ID] {
+			selected = append(selected, item)
+		}
+	}
+
+	// batchRemoveSkusFromCart removes the checked goods
+	if err = jd.selectOnly(items); err != nil {
+		return err
+	}
+	if err = jd.cartAction(URLRemoveItems, map[string]string{"t": "0"}); err != nil {
+		jd.Logger.Error(jd.msg(msgCartActionFailed), strings.Join(IDs, ","), err)
+		return err
+	}
+	return jd.itemAction(URLSelectItem, selected)
+}
+
+// ClearCart remove all the goods from cart
+//
+func (jd *JingDong) ClearCart() error {
+	if err := jd.SelectAll(); err != nil {
+		return err
+	}
+	if err := jd.cartAction(URLRemoveItems, map[string]string{"t": "0"}); err != nil {
+		jd.Logger.Error(jd.msg(msgCartActionFailed), "*", err)
+		return err
+	}
+	return nil
+}
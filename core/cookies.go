@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 )
 
 type CookieJarType int8
@@ -33,6 +34,7 @@ type JarOption struct {
 // SimpleJar implement http.CookieJar to handle cookies
 //
 type SimpleJar struct {
+	mu       sync.Mutex
 	filename string
 	jarType  CookieJarType
 	cookies  []*http.Cookie
@@ -66,21 +68,25 @@ func (jar *SimpleJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
 		return
 	}
 
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	// the cookies handed out by Cookies are never written, a new one
+	// replaces the old in the slice
 	for _, newone := range cookies {
-		var cookie *http.Cookie
-		for _, old := range jar.cookies {
+		cookie := *newone
+		found := false
+		for i, old := range jar.cookies {
 			if newone.Name == old.Name {
-				cookie = old
+				jar.cookies[i] = &cookie
+				found = true
 				break
 			}
 		}
 
-		if cookie == nil {
-			cookie = new(http.Cookie)
-			jar.cookies = append(jar.cookies, cookie)
+		if !found {
+			jar.cookies = append(jar.cookies, &cookie)
 		}
-
-		*cookie = *newone
 	}
 }
 
@@ -89,17 +95,25 @@ func (jar *SimpleJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
 // restrictions such as in RFC 6265.
 //
 func (jar *SimpleJar) Cookies(u *url.URL) []*http.Cookie {
-	s := len(jar.cookies)
-	return jar.cookies[0:s]
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	cookies := make([]*http.Cookie, len(jar.cookies))
+	copy(cookies, jar.cookies)
+	return cookies
 }
 
 // Load used to deserialization cookies data from file
 //
 func (jar *SimpleJar) Load() error {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
 	switch jar.jarType {
 	case JarGob:
 		fd, err := os.Open(jar.filename)
 		if err == nil {
+			defer fd.Close()
 			err = gob.NewDecoder(fd).Decode(&jar.cookies)
 		} else if os.IsNotExist(err) {
 			err = nil
@@ -109,6 +123,7 @@ func (jar *SimpleJar) Load() error {
 	case JarJson:
 		fd, err := os.Open(jar.filename)
 		if err == nil {
+			defer fd.Close()
 			err = json.NewDecoder(fd).Decode(&jar.cookies)
 		} else if os.IsNotExist(err) {
 			err = nil
@@ -126,6 +141,9 @@ func (jar *SimpleJar) Load() error {
 // Persist used to serialization cookies data into file
 //
 func (jar *SimpleJar) Persist() error {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
 	if len(jar.cookies) == 0 {
 		return nil
 	}
@@ -134,6 +152,7 @@ func (jar *SimpleJar) Persist() error {
 	case JarGob:
 		fd, err := os.Create(jar.filename)
 		if err == nil {
+			defer fd.Close()
 			err = gob.NewEncoder(fd).Encode(jar.cookies)
 		}
 		return err
@@ -141,6 +160,7 @@ func (jar *SimpleJar) Persist() error {
 	case JarJson:
 		fd, err := os.Create(jar.filename)
 		if err == nil {
+			defer fd.Close()
 			enc := json.NewEncoder(fd)
 			enc.SetIndent("", "    ")
 			err = enc.Encode(jar.cookies)
@@ -158,12 +178,61 @@ func (jar *SimpleJar) Persist() error {
 // Clean cookies if not valid anymore
 //
 func (jar *SimpleJar) Clean() {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	jar.cookies = jar.cookies[0:0]
+}
+
+// snapshot copy the cookies, so that restore is not affected by Clean
+//
+func (jar *SimpleJar) snapshot() []http.Cookie {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	cookies := make([]http.Cookie, len(jar.cookies))
+	for i, c := range jar.cookies {
+		cookies[i] = *c
+	}
+	return cookies
+}
+
+// restore replace the cookies by a snapshot
+//
+func (jar *SimpleJar) restore(cookies []http.Cookie) {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	jar.cookies = jar.cookies[0:0]
+	for i := range cookies {
+		c := cookies[i]
+		jar.cookies = append(jar.cookies, &c)
+	}
+}
+
+// Delete clean cookies and remove the persisted file
+//
+func (jar *SimpleJar) Delete() error {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
 	jar.cookies = jar.cookies[0:0]
+	if jar.jarType == JarMemory {
+		return nil
+	}
+
+	if err := os.Remove(jar.filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 // Get cookie vlue by name
 //
 func (jar *SimpleJar) Get(name string) string {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
 	for _, v := range jar.cookies {
 		if v.Name == name {
 			return v.Value
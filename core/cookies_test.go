@@ -0,0 +1,63 @@
+package core
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSimpleJarSetCookies(t *testing.T) {
+	jar := NewSimpleJar(JarOption{})
+	u, _ := url.Parse("https://www.jd.com")
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+	old := jar.Cookies(u)
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "3"}})
+	if got := jar.Get("a"); got != "3" {
+		t.Errorf("Get(a) = %q, want 3", got)
+	}
+	if got := len(jar.Cookies(u)); got != 2 {
+		t.Errorf("len(Cookies) = %d, want 2", got)
+	}
+	// the cookies handed out before are not changed
+	if old[0].Value != "1" {
+		t.Errorf("old cookie a = %q, want 1", old[0].Value)
+	}
+}
+
+// go test -race
+func TestSimpleJarConcurrent(t *testing.T) {
+	jar := NewSimpleJar(JarOption{})
+	u, _ := url.Parse("https://www.jd.com")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				jar.SetCookies(u, []*http.Cookie{
+					{Name: "thor", Value: strconv.Itoa(i*100 + j)},
+					{Name: "TrackID", Value: strconv.Itoa(j)},
+				})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, c := range jar.Cookies(u) {
+					_ = c.Name + c.Value
+				}
+				jar.Get("thor")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(jar.Cookies(u)); got != 2 {
+		t.Errorf("len(Cookies) = %d, want 2", got)
+	}
+}
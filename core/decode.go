@@ -0,0 +1,143 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// responseData read the body of resp, decompressed by Content-Encoding
+// and converted to UTF-8 by the charset of Content-Type or the meta tag.
+// The text without charset is GBK if it is not UTF-8, as most pages of JD.
+//
+func responseData(resp *http.Response) ([]byte, error) {
+	if resp == nil {
+		return nil, nil
+	}
+
+	reader, err := decodeReader(resp)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !isText(contentType) {
+		return data, nil
+	}
+	return toUTF8(data, contentType)
+}
+
+// decodeReader return the reader of body decompressed by each of the
+// Content-Encoding, in the reverse order they were applied
+//
+func decodeReader(resp *http.Response) (io.Reader, error) {
+	var reader io.Reader = resp.Body
+	if resp.Uncompressed {
+		return reader, nil
+	}
+
+	codings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			reader, err = gzip.NewReader(reader)
+		case "deflate":
+			reader, err = deflateReader(reader)
+		case "br":
+			reader = brotli.NewReader(reader)
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", coding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", codings[i], err)
+		}
+	}
+	return reader, nil
+}
+
+// deflateReader read the zlib stream of deflate, some servers send the
+// raw deflate stream without the zlib header instead
+//
+func deflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// zlib header: CMF 0x?8 and (CMF<<8 | FLG) % 31 == 0
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// isText report whether the content type is text to convert, the images
+// and unknown binaries are kept as is
+//
+func isText(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		strings.HasSuffix(mt, "json"),
+		strings.HasSuffix(mt, "javascript"),
+		strings.HasSuffix(mt, "xml"):
+		return true
+	}
+	return false
+}
+
+// toUTF8 convert data by the charset detected, see charset.DetermineEncoding
+//
+func toUTF8(data []byte, contentType string) ([]byte, error) {
+	enc, name, certain := charset.DetermineEncoding(data, contentType)
+	if !certain && name == "windows-1252" {
+		// no charset declared and not UTF-8
+		if !hasHighBit(data) {
+			return data, nil
+		}
+		enc, name = simplifiedchinese.GB18030, "gb18030"
+	}
+
+	if name == "utf-8" || enc == encoding.Nop {
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), nil
+	}
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", name, err)
+	}
+	return out, nil
+}
+
+func hasHighBit(data []byte) bool {
+	for _, c := range data {
+		if c >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown coding %q", coding)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func response(body []byte, contentType, contentEncoding string) *http.Response {
+	resp := &http.Response{
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(bytes.NewReader(body)),
+	}
+	if contentType != "" {
+		resp.Header.Set("Content-Type", contentType)
+	}
+	if contentEncoding != "" {
+		resp.Header.Set("Content-Encoding", contentEncoding)
+	}
+	return resp
+}
+
+func gbk(t *testing.T, s string) []byte {
+	t.Helper()
+
+	data, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestResponseDataEncoding(t *testing.T) {
+	const text = `{"success":true,"msg":"京东"}`
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"identity", "", []byte(text)},
+		{"gzip", "gzip", compress(t, "gzip", []byte(text))},
+		{"x-gzip", "x-gzip", compress(t, "gzip", []byte(text))},
+		{"deflate zlib", "deflate", compress(t, "zlib", []byte(text))},
+		{"deflate raw", "deflate", compress(t, "flate", []byte(text))},
+		{"br", "br", compress(t, "br", []byte(text))},
+		{"upper case", "GZIP", compress(t, "gzip", []byte(text))},
+		{"gzip then br", "gzip, br", compress(t, "br", compress(t, "gzip", []byte(text)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := responseData(response(tt.body, "application/json; charset=utf-8", tt.encoding))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != text {
+				t.Errorf("responseData = %q, want %q", data, text)
+			}
+		})
+	}
+}
+
+func TestResponseDataEncodingErrors(t *testing.T) {
+	for _, tt := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"compress", []byte("data")},
+		{"gzip", []byte("not gzip")},
+		{"deflate", nil},
+	} {
+		if data, err := responseData(response(tt.body, "", tt.encoding)); err == nil {
+			t.Errorf("responseData of %s %q = %q, want error", tt.encoding, tt.body, data)
+		}
+	}
+}
+
+func TestResponseDataUncompressed(t *testing.T) {
+	// the transport has decompressed the body already
+	resp := response([]byte("京东"), "text/plain; charset=utf-8", "gzip")
+	resp.Uncompressed = true
+	data, err := responseData(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "京东" {
+		t.Errorf("responseData = %q, want %q", data, "京东")
+	}
+}
+
+func TestResponseDataCharset(t *testing.T) {
+	const text = "京东商城"
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        string
+	}{
+		{"utf-8", "text/html; charset=utf-8", []byte(text), text},
+		{"utf-8 bom", "text/plain", append([]byte("\xef\xbb\xbf"), text...), text},
+		{"gbk", "text/html; charset=gbk", gbk(t, text), text},
+		{"gb2312", "application/json; charset=GB2312", gbk(t, text), text},
+		{"meta", "text/html", append([]byte(`<html><head><meta charset="gbk"></head>`), gbk(t, text)...),
+			`<html><head><meta charset="gbk"></head>` + text},
+		{"no charset utf-8", "", []byte(text), text},
+		{"no charset gbk", "", gbk(t, text), text},
+		{"no charset ascii", "text/plain", []byte("jd.com"), "jd.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := responseData(response(tt.body, tt.contentType, ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("responseData = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseDataBinary(t *testing.T) {
+	// an image is decompressed but never converted
+	png := []byte("\x89PNG\r\n\x1a\n\xb0\xc3")
+	data, err := responseData(response(compress(t, "gzip", png), "image/png", "gzip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, png) {
+		t.Errorf("responseData = %q, want %q", data, png)
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"", true},
+		{"text/html; charset=gbk", true},
+		{"application/json", true},
+		{"application/x-javascript", true},
+		{"application/xml", true},
+		{"image/png", false},
+		{"application/octet-stream", false},
+		{";;", true},
+	}
+	for _, tt := range tests {
+		if got := isText(tt.contentType); got != tt.want {
+			t.Errorf("isText(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
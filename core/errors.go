@@ -0,0 +1,234 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Errors returned by the package, all failures are wrapped so that they
+// can be checked with errors.Is / errors.As, e.g.
+//
+//    if errors.Is(err, core.ErrOutOfStock) {
+//        ...
+//    }
+//
+var (
+	// ErrNotLoggedIn means the session is expired or never logged in
+	ErrNotLoggedIn = errors.New("not logged in")
+
+	// ErrQRExpired means the login QR code expired or was canceled
+	// before the scan is confirmed
+	ErrQRExpired = errors.New("QR code expired")
+
+	// ErrRiskVerification means JD considers the login risky and asks the
+	// account owner to finish a manual verification on safe.jd.com.
+	ErrRiskVerification = errors.New("risk verification required")
+
+	// ErrOutOfStock means the goods is out of stock and rush is disabled
+	ErrOutOfStock = errors.New("out of stock")
+
+	// ErrDeadline means the deadline passed before the goods is in stock
+	ErrDeadline = errors.New("deadline exceeded")
+
+	// ErrPriceExceeded means the price or payment is higher than the limit
+	ErrPriceExceeded = errors.New("price exceeds the limit")
+
+	// ErrInvalidArea means the ship area code or name is not recognized
+	ErrInvalidArea = errors.New("invalid ship area")
+
+	// ErrAddressNotFound means no or more than one consignee address
+	// matches the query
+	ErrAddressNotFound = errors.New("address not found")
+
+	// ErrAddToCart means JD did not confirm the goods was added to cart
+	ErrAddToCart = errors.New("failed to add to cart")
+
+	// ErrCountClamped means JD changed the count of goods in cart to
+	// another one than requested, e.g. by purchase limits
+	ErrCountClamped = errors.New("count clamped")
+
+	// ErrPurchaseLimit means the count exceeds the purchase limit of the
+	// account, see PurchaseLimitError for the count allowed
+	ErrPurchaseLimit = errors.New("purchase limit exceeded")
+
+	// ErrNotInCart means the goods is not in the shopping cart
+	ErrNotInCart = errors.New("not in cart")
+
+	// ErrOrderPolicy means the goods in cart do not satisfy the order
+	// policy, so the order is not submitted
+	ErrOrderPolicy = errors.New("order policy not satisfied")
+
+	// ErrOrderRejected means JD refused to create the order
+	ErrOrderRejected = errors.New("order rejected")
+
+	// ErrOrderStateUnknown means the order submission failed midway and
+	// it can not tell whether the order was created, check the order list
+	// before submitting again
+	ErrOrderStateUnknown = errors.New("order state unknown")
+
+	// ErrThrottled means JD asks to slow down by HTTP 429 or 503
+	ErrThrottled = errors.New("throttled")
+
+	// ErrParse means the response from JD can not be recognized
+	ErrParse = errors.New("unrecognized response")
+)
+
+// maxSnippetLen is the max length of response data kept in ParseError
+//
+var maxSnippetLen = 120
+
+// ItemError is the error of a goods, the goods of RushReport fail with
+// it. Err is the cause, e.g. ErrOutOfStock or *PurchaseLimitError.
+//
+type ItemError struct {
+	ID  string
+	Err error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%s: %v", e.ID, e.Err)
+}
+
+// Unwrap return the cause
+//
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// itemError wrap err as the error of goods ID, unless it is already the
+// error of a goods, e.g. an alternative of ID
+//
+func itemError(ID string, err error) error {
+	var ie *ItemError
+	if err == nil || errors.As(err, &ie) {
+		return err
+	}
+	return &ItemError{ID: ID, Err: err}
+}
+
+// RiskVerificationError carries the verification page returned by JD.
+// It matches ErrRiskVerification with errors.Is.
+//
+type RiskVerificationError struct {
+	URL string
+}
+
+func (e *RiskVerificationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRiskVerification, e.URL)
+}
+
+// Is report whether target is ErrRiskVerification
+//
+func (e *RiskVerificationError) Is(target error) bool {
+	return target == ErrRiskVerification
+}
+
+// OrderRejectedError carries the result code and message returned by
+// submitOrder.action. It matches ErrOrderRejected with errors.Is.
+//
+type OrderRejectedError struct {
+	Code    string
+	Message string
+}
+
+func (e *OrderRejectedError) Error() string {
+	return fmt.Sprintf("%s (%s : %s)", ErrOrderRejected, e.Code, e.Message)
+}
+
+// Is report whether target is ErrOrderRejected
+//
+func (e *OrderRejectedError) Is(target error) bool {
+	return target == ErrOrderRejected
+}
+
+// CountClampedError carries the count requested and the count accepted
+// by JD. It matches ErrCountClamped with errors.Is.
+//
+type CountClampedError struct {
+	ID        string
+	Requested int
+	Count     int
+}
+
+func (e *CountClampedError) Error() string {
+	return fmt.Sprintf("%s: %d -> %d", ErrCountClamped, e.Requested, e.Count)
+}
+
+// Is report whether target is ErrCountClamped
+//
+func (e *CountClampedError) Is(target error) bool {
+	return target == ErrCountClamped
+}
+
+// HTTPStatusError carries the unexpected HTTP status of response.
+// It matches ErrThrottled with errors.Is for 429 and 503.
+//
+type HTTPStatusError struct {
+	Code       int
+	Status     string
+	RetryAfter time.Duration // from the Retry-After header, may be 0
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status %s", e.Status)
+}
+
+// Is report whether target is ErrThrottled for 429 and 503
+//
+func (e *HTTPStatusError) Is(target error) bool {
+	return target == ErrThrottled &&
+		(e.Code == http.StatusTooManyRequests || e.Code == http.StatusServiceUnavailable)
+}
+
+// ParseError describes a response which can not be parsed, Snippet holds
+// the beginning of the response data for troubleshooting.
+// It matches ErrParse with errors.Is.
+//
+type ParseError struct {
+	What    string // what is being parsed, e.g. "stock", "order"
+	Snippet string
+	Err     error // underlying error, may be nil
+}
+
+func newParseError(what string, data []byte, err error) *ParseError {
+	return &ParseError{
+		What:    what,
+		Snippet: snippet(data),
+		Err:     err,
+	}
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s %s", e.What, ErrParse)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Snippet != "" {
+		msg += fmt.Sprintf(" [%s]", e.Snippet)
+	}
+	return msg
+}
+
+// Is report whether target is ErrParse
+//
+func (e *ParseError) Is(target error) bool {
+	return target == ErrParse
+}
+
+// Unwrap return the underlying error
+//
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// snippet return the beginning of response data
+//
+func snippet(data []byte) string {
+	rs := []rune(string(data))
+	if len(rs) > maxSnippetLen {
+		return string(rs[:maxSnippetLen]) + "..."
+	}
+	return string(rs)
+}
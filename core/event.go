@@ -0,0 +1,68 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Phase of the buying process reported by Event
+//
+type Phase string
+
+const (
+	PhaseLogin        Phase = "login"
+	PhaseSKUDetail    Phase = "sku_detail"
+	PhaseStockPoll    Phase = "stock_poll"
+	PhaseAddToCart    Phase = "add_to_cart"
+	PhaseOrderPreview Phase = "order_preview"
+	PhaseSubmit       Phase = "submit"
+)
+
+// Event is emitted to JDConfig.OnEvent when a phase finished
+//
+type Event struct {
+	Time    time.Time              `json:"time"`
+	Profile string                 `json:"profile,omitempty"`
+	Phase   Phase                  `json:"phase"`
+	SKU     string                 `json:"sku,omitempty"`
+	Elapsed time.Duration          `json:"-"`
+	OK      bool                   `json:"ok"`
+	Error   string                 `json:"error,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// MarshalJSON encode Elapsed as milliseconds
+//
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	return json.Marshal(struct {
+		event
+		ElapsedMS float64 `json:"elapsed_ms"`
+	}{
+		event:     event(e),
+		ElapsedMS: float64(e.Elapsed) / float64(time.Millisecond),
+	})
+}
+
+// emit send the event of phase started at start to OnEvent
+//
+func (jd *JingDong) emit(phase Phase, sku string, start time.Time, err error, data map[string]interface{}) {
+	if jd.OnEvent == nil {
+		return
+	}
+
+	ev := Event{
+		Time:    time.Now(),
+		Profile: jd.Profile,
+		Phase:   phase,
+		SKU:     sku,
+		Elapsed: time.Since(start),
+		OK:      err == nil,
+		Data:    data,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	jd.OnEvent(ev)
+}
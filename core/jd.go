@@ -2,27 +2,25 @@ package core
 
 import (
 	"bytes"
-	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math/rand"
 	"mime"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/axgle/mahonia"
 	sjson "github.com/bitly/go-simplejson"
-	clog "gopkg.in/clog.v1"
 )
 
 const (
@@ -52,7 +50,7 @@ var (
 		"User-Agent":      "Chrome/51.0.2704.103",
 		"ContentType":     "application/json", //"text/html; charset=utf-8",
 		"Connection":      "keep-alive",
-		"Accept-Encoding": "gzip, deflate",
+		"Accept-Encoding": "gzip, deflate, br",
 		"Accept-Language": "zh-CN,zh;q=0.8",
 	}
 
@@ -60,6 +58,9 @@ var (
 	cookieFile   = "jd.cookies"
 	qrCodeFile   = "jd.qr"
 	strSeperater = strings.Repeat("+", 60)
+
+	venderJD   = "8888" // JD self-operated
+	reVenderID = regexp.MustCompile(`venderId\s*:\s*(\d+)`)
 )
 
 // JDConfig ...
@@ -68,17 +69,117 @@ type JDConfig struct {
 	ShipArea   string        // shipping area
 	AutoRush   bool          // continue rush when out of stock
 	AutoSubmit bool          // whether submit the order
+
+	// Profile is the account profile name, each profile has its own
+	// cookies file "jd.<profile>.cookies". Empty for the default "jd.cookies".
+	Profile string
+
+	// MaxPayment refuse to submit the order if the payment on checkout
+	// page is higher, 0 means no limit
+	MaxPayment float64
+
+	// Address is the ID or keyword of the consignee address, selected
+	// before rush, and the ShipArea follows the address
+	Address string
+
+	// Poll decide the interval between stock polls, default to FixedPoll
+	// of Period
+	Poll PollStrategy
+
+	// MaxPollErrors is the max count of consecutive errors of stock polls
+	// before giving up the goods, default to 1
+	MaxPollErrors int
+
+	// Workers is the max count of goods sending requests at the same time
+	// in RushBuy, the stock of each goods is still polled on its own, so
+	// that the goods out of stock do not block the others. Default to all
+	// the goods.
+	Workers int
+
+	// OrderPolicy decide whether to submit the order after rush, by the
+	// goods in cart, default to OrderAny
+	OrderPolicy OrderPolicy
+
+	// LimitPolicy decide what to do when the count exceeds the purchase
+	// limit, default to LimitFail
+	LimitPolicy LimitPolicy
+
+	// Deadline stop refreshing the stock when passed, zero means no
+	// deadline. It or PolicyDeadline is required to rush more than one
+	// goods with AutoRush.
+	Deadline time.Time
+
+	// PolicyDeadline is when the OrderPolicy is checked at the latest in
+	// RushBuy, the goods not in stock by then are given up, e.g. to submit
+	// the goods in cart if not all of them come in stock. Zero for Deadline.
+	PolicyDeadline time.Time
+
+	// Notifier present the QR code and risk verification page,
+	// default to DesktopNotifier
+	Notifier Notifier
+
+	// Logger receive the logs, default to NopLogger
+	Logger Logger
+
+	// Lang is the language of log messages, default to DefaultLang
+	Lang Lang
+
+	// VerifyTimeout is how long to wait for the user to finish JD risk
+	// verification before giving up, 0 means fail immediately
+	VerifyTimeout time.Duration
+
+	// KeepAlive is the interval of the background session keeper started
+	// after login, 0 means disabled. See KeepSession.
+	KeepAlive time.Duration
+
+	// Proxy is the URL of the HTTP or SOCKS5 proxy of the profile, e.g.
+	// socks5://127.0.0.1:1080. Login, cart and order always go through
+	// it, so that the session stays on the same IP. Empty for the proxy of
+	// the environment variables HTTP_PROXY and HTTPS_PROXY.
+	Proxy string
+
+	// ProxyPool rotates the proxies of the stock and price queries, which
+	// do not need the session. nil to use Proxy.
+	ProxyPool *ProxyPool
+
+	// Middlewares wrap the requests to JD, after the default headers and
+	// logging, before the retries and rate limits. See Middleware.
+	Middlewares []Middleware
+
+	// MaxRetries is the max count of retries of the idempotent requests on
+	// network errors and gateway failures, and of checking the order list
+	// after the order submission failed midway, default to 2, negative
+	// means no retry
+	MaxRetries int
+
+	// OnEvent is called when each phase of login and buying finished,
+	// it may be called from multiple goroutines concurrently
+	OnEvent func(Event)
 }
 
 // SKUInfo ...
 type SKUInfo struct {
-	ID        string
-	Price     string
-	Count     int    // buying count
-	State     string // stock state 33 : on sale, 34 : out of stock
-	StateName string // "现货" / "无货"
-	Name      string
-	Link      string
+	ID        string  `json:"id"`
+	Price     string  `json:"price"`
+	Count     int     `json:"count"`               // buying count
+	MaxPrice  float64 `json:"max_price,omitempty"` // max price to buy
+	State     string  `json:"state"`               // stock state 33 : on sale, 34 : out of stock
+	StateName string  `json:"state_name"`          // "现货" / "无货"
+	Name      string  `json:"name"`
+	Link      string  `json:"link"`
+	Limit     int     `json:"limit,omitempty"`     // purchase limit of the account, 0 : no limit
+	VenderID  string  `json:"vender_id,omitempty"` // seller, 8888 : JD self-operated
+	PromoID   string  `json:"promo_id,omitempty"`  // promotion of the goods in cart
+	TargetID  string  `json:"target_id,omitempty"` // target promotion of the goods in cart
+}
+
+// OrderPreview is the order summary shown on the checkout page
+type OrderPreview struct {
+	WarePrice string `json:"ware_price"` // total price of goods
+	ShipPrice string `json:"ship_price"` // freight
+	Payment   string `json:"payment"`    // amount to pay
+	Mobile    string `json:"mobile"`
+	Address   string `json:"address"`
 }
 
 // JingDong wrap jing dong operation
@@ -87,6 +188,13 @@ type JingDong struct {
 	client *http.Client
 	jar    *SimpleJar
 	token  string
+
+	loginMu  sync.Mutex
+	keeperMu sync.Mutex
+	keeper   *sessionKeeper
+
+	sessMu  sync.Mutex
+	loginAt time.Time
 }
 
 // NewJingDong create an object to wrap JingDong related operation
@@ -96,34 +204,76 @@ func NewJingDong(option JDConfig) *JingDong {
 		JDConfig: option,
 	}
 
+	if jd.Notifier == nil {
+		jd.Notifier = DesktopNotifier{}
+	}
+	if jd.Logger == nil {
+		jd.Logger = NopLogger{}
+	}
+	if jd.Lang == "" {
+		jd.Lang = DefaultLang
+	}
+	if jd.MaxRetries == 0 {
+		jd.MaxRetries = 2
+	}
+	if jd.Poll == nil {
+		jd.Poll = FixedPoll{Period: jd.Period}
+	}
+
 	jd.jar = NewSimpleJar(JarOption{
 		JarType:  JarGob,
-		Filename: cookieFile,
+		Filename: jd.profileFile(cookieFile),
 	})
 
 	if err := jd.jar.Load(); err != nil {
-		clog.Error(0, "加载Cookies失败: %s", err)
+		jd.Logger.Error(jd.msg(msgLoadCookiesFailed), err)
 		jd.jar.Clean()
 	}
 
 	jd.client = &http.Client{
-		Timeout: time.Minute,
-		Jar:     jd.jar,
+		Timeout:   time.Minute,
+		Jar:       jd.jar,
+		Transport: Chain(jd.transport(), jd.middlewares()...),
 	}
 
 	return jd
 }
 
+// Logout clean the cookies and remove the cookies file
+//
+func (jd *JingDong) Logout() error {
+	jd.stopKeeper()
+
+	jd.sessMu.Lock()
+	jd.loginAt = time.Time{}
+	jd.sessMu.Unlock()
+
+	return jd.jar.Delete()
+}
+
 // Release the resource opened
 //
 func (jd *JingDong) Release() {
+	jd.stopKeeper()
+
 	if jd.jar != nil {
 		if err := jd.jar.Persist(); err != nil {
-			clog.Error(0, "Failed to persist cookiejar. error %+v.", err)
+			jd.Logger.Error(jd.msg(msgSaveCookiesFailed), err)
 		}
 	}
 }
 
+// profileFile insert the profile name into filename, e.g. jd.cookies
+// to jd.<profile>.cookies
+//
+func (jd *JingDong) profileFile(filename string) string {
+	if jd.Profile == "" {
+		return filename
+	}
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "." + jd.Profile + ext
+}
+
 //
 //
 func truncate(str string) string {
@@ -134,83 +284,6 @@ func truncate(str string) string {
 	return str
 }
 
-// if response data compressed by gzip, unzip first
-//
-func responseData(resp *http.Response) []byte {
-	if resp == nil {
-		return nil
-	}
-
-	var reader io.Reader
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		//clog.Trace("Encoding: %+v", resp.Header.Get("Content-Encoding"))
-		reader, _ = gzip.NewReader(resp.Body)
-	default:
-		reader = resp.Body
-	}
-
-	data, err := ioutil.ReadAll(reader)
-	if err != nil {
-		clog.Error(0, "读取响应数据失败: %+v", err)
-		return nil
-	}
-
-	return data
-}
-
-//
-//
-func applyCustomHeader(req *http.Request, header map[string]string) {
-	if req == nil || len(header) == 0 {
-		return
-	}
-
-	for key, val := range header {
-		req.Header.Set(key, val)
-	}
-}
-
-//
-func (jd *JingDong) validateLogin(URL string) bool {
-	var (
-		err  error
-		req  *http.Request
-		resp *http.Response
-	)
-
-	if req, err = http.NewRequest("GET", URL, nil); err != nil {
-		clog.Info("请求(%+v)失败: %+v", URL, err)
-		return false
-	}
-
-	jd.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		// disable redirect
-		return http.ErrUseLastResponse
-	}
-
-	defer func() {
-		// restore to default
-		jd.client.CheckRedirect = nil
-	}()
-
-	if resp, err = jd.client.Do(req); err != nil {
-		clog.Info("需要重新登录: %+v", err)
-		return false
-	}
-
-	defer resp.Body.Close()
-	data, _ := ioutil.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		clog.Info("需要重新登录")
-		return false
-	}
-
-	clog.Trace("Response Data: %s", string(data))
-	return true
-}
-
 // load the login page
 //
 func (jd *JingDong) loginPage(URL string) error {
@@ -221,14 +294,12 @@ func (jd *JingDong) loginPage(URL string) error {
 	)
 
 	if req, err = http.NewRequest("GET", URL, nil); err != nil {
-		clog.Info("请求(%+v)失败: %+v", URL, err)
+		jd.Logger.Info(jd.msg(msgRequestFailed), URL, err)
 		return err
 	}
 
-	applyCustomHeader(req, DefaultHeaders)
-
 	if resp, err = jd.client.Do(req); err != nil {
-		clog.Info("请求登录页失败: %+v", err)
+		jd.Logger.Info(jd.msg(msgLoginPageFailed), err)
 		return err
 	}
 
@@ -253,39 +324,50 @@ func (jd *JingDong) loadQRCode(URL string) (string, error) {
 	u.RawQuery = q.Encode()
 
 	if req, err = http.NewRequest("GET", u.String(), nil); err != nil {
-		clog.Error(0, "请求(%+v)失败: %+v", URL, err)
+		jd.Logger.Error(jd.msg(msgRequestFailed), URL, err)
 		return "", err
 	}
 
-	applyCustomHeader(req, DefaultHeaders)
 	if resp, err = jd.client.Do(req); err != nil {
-		clog.Error(0, "下载二维码失败: %+v", err)
+		jd.Logger.Error(jd.msg(msgQRDownloadFailed), err)
 		return "", err
 	}
 
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		clog.Error(0, "http status : %d/%s", resp.StatusCode, resp.Status)
+		jd.Logger.Error(jd.msg(msgHTTPStatus), resp.StatusCode, resp.Status)
+		return "", fmt.Errorf("download QR code: %s", resp.Status)
+	}
+
+	// the image is compressed like the pages, but has no charset
+	reader, err := decodeReader(resp)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgQRDownloadFailed), err)
+		return "", err
 	}
 
 	// from mime get QRCode image type
 	//  content-type:image/png
 	//
-	filename := qrCodeFile + ".png"
+	filename := jd.profileFile(qrCodeFile) + ".png"
 	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
-	if typ, e := mime.ExtensionsByType(mt); e == nil {
-		filename = qrCodeFile + typ[0]
+	if typ, e := mime.ExtensionsByType(mt); e == nil && len(typ) > 0 {
+		filename = jd.profileFile(qrCodeFile) + typ[0]
 	}
 
 	dir, _ := os.Getwd()
 	filename = filepath.Join(dir, filename)
-	clog.Trace("QR Image: %s", filename)
+	jd.Logger.Trace("QR Image: %s", filename)
 
-	file, _ := os.Create(filename)
+	file, err := os.Create(filename)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgQRSaveFailed), err)
+		return "", err
+	}
 	defer file.Close()
 
-	if _, err = io.Copy(file, resp.Body); err != nil {
-		clog.Error(0, "下载二维码失败: %+v", err)
+	if _, err = io.Copy(file, reader); err != nil {
+		jd.Logger.Error(jd.msg(msgQRDownloadFailed), err)
 		return "", err
 	}
 
@@ -295,6 +377,35 @@ func (jd *JingDong) loadQRCode(URL string) (string, error) {
 // wait scan result
 //
 func (jd *JingDong) waitForScan(URL string) error {
+	jd.token = ""
+	for retry := 50; retry != 0; retry-- {
+		code, ticket, err := jd.checkScan(URL)
+		if err != nil {
+			return err
+		}
+
+		// 201 : not scanned, 202 : wait for confirm on phone
+		// 203 : expired, 205 : canceled
+		switch code {
+		case 200:
+			jd.token = ticket
+			jd.Logger.Info("token : %+v", jd.token)
+			return nil
+		case 203, 205:
+			return ErrQRExpired
+		default:
+			time.Sleep(time.Second * 3)
+		}
+	}
+
+	jd.Logger.Info(jd.msg(msgQRNoResult))
+	return ErrQRExpired
+}
+
+// checkScan query the scan result of QR code once, return the code and
+// the ticket of code 200, code 0 if JD does not respond 200
+//
+func (jd *JingDong) checkScan(URL string) (int, string, error) {
 	var (
 		err    error
 		req    *http.Request
@@ -318,56 +429,52 @@ func (jd *JingDong) waitForScan(URL string) error {
 	u.RawQuery = q.Encode()
 
 	if req, err = http.NewRequest("GET", u.String(), nil); err != nil {
-		clog.Info("请求(%+v)失败: %+v", URL, err)
-		return err
+		jd.Logger.Info(jd.msg(msgRequestFailed), URL, err)
+		return 0, "", err
 	}
 
 	// mush have
 	req.Host = "qr.m.jd.com"
 	req.Header.Set("Referer", "https://passport.jd.com/new/login.aspx")
-	applyCustomHeader(req, DefaultHeaders)
 
-	for retry := 50; retry != 0; retry-- {
-		if resp, err = jd.client.Do(req); err != nil {
-			clog.Info("二维码失效：%+v", err)
-			break
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			respMsg := string(responseData(resp))
-			resp.Body.Close()
+	if resp, err = jd.client.Do(req); err != nil {
+		jd.Logger.Info(jd.msg(msgQRInvalid), err)
+		return 0, "", err
+	}
+	defer resp.Body.Close()
 
-			n1 := strings.Index(respMsg, "(")
-			n2 := strings.Index(respMsg, ")")
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", nil
+	}
 
-			var js *sjson.Json
-			if js, err = sjson.NewJson([]byte(respMsg[n1+1 : n2])); err != nil {
-				clog.Error(0, "解析响应数据失败: %+v", err)
-				clog.Trace("Response data  : %+v", respMsg)
-				clog.Trace("Response Header: %+v", resp.Header)
-				break
-			}
+	data, err := responseData(resp)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgReadRespFailed), err)
+		return 0, "", err
+	}
 
-			code := js.Get("code").MustInt()
-			if code == 200 {
-				jd.token = js.Get("ticket").MustString()
-				clog.Info("token : %+v", jd.token)
-				break
-			} else {
-				clog.Info("%+v : %s", code, js.Get("msg").MustString())
-				time.Sleep(time.Second * 3)
-			}
-		} else {
-			resp.Body.Close()
-		}
+	// jQuery123456({"code" : 201, "msg" : "二维码未扫描 ，请扫描二维码"})
+	respMsg := string(data)
+	n1 := strings.Index(respMsg, "(")
+	n2 := strings.LastIndex(respMsg, ")")
+	if n1 < 0 || n2 < n1 {
+		jd.Logger.Trace("Response data  : %+v", respMsg)
+		return 0, "", newParseError("QR scan result", data, nil)
 	}
 
-	if jd.token == "" {
-		err = fmt.Errorf("未检测到QR扫码结果")
-		return err
+	var js *sjson.Json
+	if js, err = sjson.NewJson([]byte(respMsg[n1+1 : n2])); err != nil {
+		jd.Logger.Error(jd.msg(msgParseRespFailed), err)
+		jd.Logger.Trace("Response data  : %+v", respMsg)
+		jd.Logger.Trace("Response Header: %+v", resp.Header)
+		return 0, "", newParseError("QR scan result", data, err)
 	}
 
-	return nil
+	code := js.Get("code").MustInt()
+	if code != 200 {
+		jd.Logger.Info("%+v : %s", code, js.Get("msg").MustString())
+	}
+	return code, js.Get("ticket").MustString(), nil
 }
 
 // validate QR token
@@ -385,14 +492,16 @@ func (jd *JingDong) validateQRToken(URL string) error {
 	u.RawQuery = q.Encode()
 
 	if req, err = http.NewRequest("GET", u.String(), nil); err != nil {
-		clog.Info("请求(%+v)失败: %+v", URL, err)
+		jd.Logger.Info(jd.msg(msgRequestFailed), URL, err)
 		return err
 	}
 	if resp, err = jd.client.Do(req); err != nil {
-		clog.Error(0, "二维码登陆校验失败: %+v", err)
-		return nil
+		jd.Logger.Error(jd.msg(msgQRValidateFailed), err)
+		return err
 	}
 
+	defer resp.Body.Close()
+
 	//
 	// 京东有时候会认为当前登录有危险，需要手动验证
 	// url: https://safe.jd.com/dangerousVerify/index.action?username=...
@@ -403,77 +512,138 @@ func (jd *JingDong) validateQRToken(URL string) error {
 			Token      string `json:"token"`
 			URL        string `json:"url"`
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&res); err == nil {
-			if res.URL != "" {
-				verifyURL := res.URL
-				if !strings.HasPrefix(verifyURL, "https:") {
-					verifyURL = "https:" + verifyURL
-				}
-				clog.Error(2, "安全验证: %s", verifyURL)
-				jd.runCommand(verifyURL)
+		data, err := responseData(resp)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(data, &res); err != nil {
+			return newParseError("QR ticket validation", data, err)
+		}
+		if res.URL != "" {
+			verifyURL := res.URL
+			if !strings.HasPrefix(verifyURL, "https:") {
+				verifyURL = "https:" + verifyURL
 			}
+			jd.Logger.Warn(jd.msg(msgRiskVerify), verifyURL)
+			return &RiskVerificationError{URL: verifyURL}
 		}
-		return fmt.Errorf("login failed")
+		jd.Logger.Info(jd.msg(msgLoginFailedCode), res.ReturnCode)
+		return fmt.Errorf("QR ticket validation failed (%d): %w", res.ReturnCode, ErrNotLoggedIn)
 	}
 
-	if resp.StatusCode == http.StatusOK {
-		//data, _ := ioutil.ReadAll(resp.Body)
-		//clog.Info("Body: %s.", string(data))
-		clog.Info("登陆成功, P3P: %s", resp.Header.Get("P3P"))
-	} else {
-		clog.Info("登陆失败")
-		err = fmt.Errorf("%+v", resp.Status)
+	if resp.StatusCode != http.StatusOK {
+		jd.Logger.Info(jd.msg(msgLoginFailed))
+		return fmt.Errorf("QR ticket validation failed (%s): %w", resp.Status, ErrNotLoggedIn)
 	}
 
-	resp.Body.Close()
+	//data, _ := ioutil.ReadAll(resp.Body)
+	//jd.Logger.Info("Body: %s.", string(data))
+	jd.Logger.Info(jd.msg(msgLoginSuccess), resp.Header.Get("P3P"))
 	return nil
 }
 
-func (jd *JingDong) runCommand(strCmd string) error {
-	var err error
-	var cmd *exec.Cmd
+// waitForVerify present the risk verification page to user, then wait
+// until the verification finished or timeout.
+//
+// It is not known whether JD issues a fresh QR ticket after verification,
+// so the QR check is still polled: a new ticket replaces the known one,
+// otherwise the known ticket is validated again. This relies on the
+// ticket staying valid while the verification is pending, with the risk
+// error answered until it is done. Any other error fails at once instead
+// of waiting out the timeout.
+//
+func (jd *JingDong) waitForVerify(URL string, verr error) error {
+	if err := jd.Notifier.RiskVerify(URL); err != nil {
+		jd.Logger.Error(jd.msg(msgOpenVerifyFailed), err)
+	}
 
-	// for different platform
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.Command("cmd", "/c", "start", strCmd)
-	case "linux":
-		cmd = exec.Command("eog", strCmd)
-	default:
-		cmd = exec.Command("open", strCmd)
+	if jd.VerifyTimeout <= 0 {
+		return verr
 	}
 
-	// just start, do not wait it complete
-	if err = cmd.Start(); err != nil {
-		if runtime.GOOS == "linux" {
-			cmd = exec.Command("gnome-open", strCmd)
-			return cmd.Start()
+	jd.Logger.Info(jd.msg(msgWaitVerify), jd.VerifyTimeout)
+	deadline := time.Now().Add(jd.VerifyTimeout)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(time.Second * 5)
+
+		code, ticket, err := jd.checkScan(URLForQR[2])
+		switch {
+		case err != nil:
+			// keep the known ticket
+		case code == 203 || code == 205:
+			return ErrQRExpired
+		case code == 200 && ticket != "":
+			jd.token = ticket
+		}
+		if jd.token == "" {
+			return verr
+		}
+
+		if err = jd.validateQRToken(URLForQR[3]); err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrRiskVerification) {
+			return err
 		}
-		return err
 	}
-	return nil
+
+	jd.Logger.Error(jd.msg(msgWaitVerifyTimeout))
+	return verr
 }
 
 // TODO(adyzng) updatethe login logic
 // Login used to login JD by QR code.
 // if the cookies file exits, will try cookies first.
 //
-func (jd *JingDong) Login(args ...interface{}) error {
-	clog.Info(strSeperater)
+func (jd *JingDong) Login(args ...interface{}) (err error) {
+	jd.loginMu.Lock()
+	defer jd.loginMu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		var data map[string]interface{}
+		if err == nil {
+			data = map[string]interface{}{"user": jd.session().Nick}
+		}
+		jd.emit(PhaseLogin, "", start, err, data)
+	}()
 
-	if jd.validateLogin(URLForQR[4]) {
-		clog.Info("无需重新登录")
+	jd.Logger.Info(strSeperater)
+
+	if ss, err := jd.validateLogin(URLForQR[4]); err == nil {
+		jd.Logger.Info(jd.msg(msgAlreadyLogin), ss.Nick)
+		jd.loginDone()
 		return nil
 	}
 
-	var (
-		err   error
-		qrImg string
-	)
+	return jd.loginByQR()
+}
 
-	clog.Info("请打开京东手机客户端，准备扫码登陆:")
+// loginByQR run the QR code login flow, caller must hold loginMu. The
+// cookies are restored if the login failed.
+//
+func (jd *JingDong) loginByQR() (err error) {
+	var qrImg string
+
+	jd.Logger.Info(jd.msg(msgScanQR))
+	saved := jd.jar.snapshot()
 	jd.jar.Clean()
 
+	jd.sessMu.Lock()
+	loginAt := jd.loginAt
+	jd.loginAt = time.Time{}
+	jd.sessMu.Unlock()
+
+	defer func() {
+		if err != nil {
+			jd.jar.restore(saved)
+			jd.sessMu.Lock()
+			jd.loginAt = loginAt
+			jd.sessMu.Unlock()
+		}
+	}()
+
 	if err = jd.loginPage(URLForQR[0]); err != nil {
 		return err
 	}
@@ -482,9 +652,8 @@ func (jd *JingDong) Login(args ...interface{}) error {
 		return err
 	}
 
-	// just start, do not wait it complete
-	if err = jd.runCommand(qrImg); err != nil {
-		clog.Info("打开二维码图片失败: %+v.", err)
+	if err = jd.Notifier.QRCode(qrImg); err != nil {
+		jd.Logger.Info(jd.msg(msgOpenQRFailed), err)
 		return err
 	}
 
@@ -493,94 +662,65 @@ func (jd *JingDong) Login(args ...interface{}) error {
 	}
 
 	if err = jd.validateQRToken(URLForQR[3]); err != nil {
-		return err
+		var rv *RiskVerificationError
+		if !errors.As(err, &rv) {
+			return err
+		}
+		if err = jd.waitForVerify(rv.URL, err); err != nil {
+			return err
+		}
 	}
 
-	//http.Post()
+	jd.loginDone()
 	return nil
 }
 
-// CartDetails get the shopping cart details
+// loginDone mark the session valid and start the session keeper if enabled
 //
-func (jd *JingDong) CartDetails() error {
-	clog.Info(strSeperater)
-	clog.Info("购物车详情>")
-
-	var (
-		err  error
-		req  *http.Request
-		resp *http.Response
-		doc  *goquery.Document
-	)
-
-	if req, err = http.NewRequest("GET", URLCartInfo, nil); err != nil {
-		clog.Error(0, "请求(%+v)失败: %+v", URLCartInfo, err)
-		return err
+func (jd *JingDong) loginDone() {
+	jd.sessMu.Lock()
+	if jd.loginAt.IsZero() {
+		jd.loginAt = time.Now()
 	}
+	jd.sessMu.Unlock()
 
-	if resp, err = jd.client.Do(req); err != nil {
-		clog.Error(0, "获取购物车详情错误: %+v", err)
-		return err
+	if err := jd.jar.Persist(); err != nil {
+		jd.Logger.Error(jd.msg(msgSaveCookiesFailed), err)
 	}
 
-	defer resp.Body.Close()
-	if doc, err = goquery.NewDocumentFromReader(resp.Body); err != nil {
-		clog.Error(0, "分析购物车页面错误: %+v.", err)
-		return err
+	jd.keeperMu.Lock()
+	if jd.keeper != nil {
+		atomic.StoreInt32(&jd.keeper.valid, 1)
 	}
+	jd.keeperMu.Unlock()
 
-	clog.Info("购买  数量  价格      总价      编号        商品")
-	cartFormat := "%-6s%-6s%-10s%-10s%-12s%s"
-
-	doc.Find("div.item-form").Each(func(i int, p *goquery.Selection) {
-		check := " -"
-		checkTag := p.Find("div.cart-checkbox input").Eq(0)
-		if _, exist := checkTag.Attr("checked"); exist {
-			check = " +"
-		}
-
-		count := "0"
-		countTag := p.Find("div.quantity-form input").Eq(0)
-		if val, exist := countTag.Attr("value"); exist {
-			count = val
-		}
-
-		pid := ""
-		hrefTag := p.Find("div.p-img a").Eq(0)
-		if href, exist := hrefTag.Attr("href"); exist {
-			// http://item.jd.com/2967929.html
-			pos1 := strings.LastIndex(href, "/")
-			pos2 := strings.LastIndex(href, ".")
-			pid = href[pos1+1 : pos2]
-		}
-
-		price := strings.Trim(p.Find("div.p-price strong").Eq(0).Text(), " ")
-		total := strings.Trim(p.Find("div.p-sum strong").Eq(0).Text(), " ")
-		gname := strings.Trim(p.Find("div.p-name a").Eq(0).Text(), " \n\t")
-		gname = truncate(gname)
-		clog.Info(cartFormat, check, count, price, total, pid, gname)
-	})
-
-	totalCount := strings.Trim(doc.Find("div.amount-sum em").Eq(0).Text(), " ")
-	totalValue := strings.Trim(doc.Find("span.sumPrice em").Eq(0).Text(), " ")
-	clog.Info("总数: %s", totalCount)
-	clog.Info("总额: %s", totalValue)
-
-	return nil
+	jd.KeepSession(jd.KeepAlive)
 }
 
 // OrderInfo shows the order detail information
 //
-func (jd *JingDong) OrderInfo() error {
+func (jd *JingDong) OrderInfo() (order *OrderPreview, err error) {
 	var (
-		err  error
 		req  *http.Request
 		resp *http.Response
 		doc  *goquery.Document
 	)
 
-	clog.Info(strSeperater)
-	clog.Info("订单详情>")
+	start := time.Now()
+	defer func() {
+		var data map[string]interface{}
+		if order != nil {
+			data = map[string]interface{}{
+				"ware_price": order.WarePrice,
+				"ship_price": order.ShipPrice,
+				"payment":    order.Payment,
+			}
+		}
+		jd.emit(PhaseOrderPreview, "", start, err, data)
+	}()
+
+	jd.Logger.Info(strSeperater)
+	jd.Logger.Info(jd.msg(msgOrderTitle))
 
 	u, _ := url.Parse(URLOrderInfo)
 	q := u.Query()
@@ -588,50 +728,112 @@ func (jd *JingDong) OrderInfo() error {
 	u.RawQuery = q.Encode()
 
 	if req, err = http.NewRequest("GET", u.String(), nil); err != nil {
-		clog.Error(0, "请求(%+v)失败: %+v", URLCartInfo, err)
-		return err
+		jd.Logger.Error(jd.msg(msgRequestFailed), URLCartInfo, err)
+		return nil, err
 	}
 
 	if resp, err = jd.client.Do(req); err != nil {
-		clog.Error(0, "获取订单页错误: %+v", err)
-		return err
+		jd.Logger.Error(jd.msg(msgOrderFailed), err)
+		return nil, err
 	}
 
 	defer resp.Body.Close()
-	if doc, err = goquery.NewDocumentFromReader(resp.Body); err != nil {
-		clog.Error(0, "分析订单页错误: %+v.", err)
-		return err
+	data, err := responseData(resp)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgReadRespFailed), err)
+		return nil, err
+	}
+	if doc, err = goquery.NewDocumentFromReader(bytes.NewReader(data)); err != nil {
+		jd.Logger.Error(jd.msg(msgOrderParseFailed), err)
+		return nil, newParseError("order info", data, err)
 	}
 
 	//h, _ := doc.Find("div.order-summary").Html()
-	//clog.Trace("订单页：%s", h)
+	//jd.Logger.Trace("订单页：%s", h)
 
-	if order := doc.Find("div.order-summary").Eq(0); order != nil {
-		warePrice := strings.Trim(order.Find("#warePriceId").Text(), " \t\n")
-		shipPrice := strings.Trim(order.Find("#freightPriceId").Text(), " \t\n")
-		clog.Info("总金额: %s", warePrice)
-		clog.Info("　运费: %s", shipPrice)
+	order = &OrderPreview{}
+	if summary := doc.Find("div.order-summary").Eq(0); summary != nil {
+		order.WarePrice = strings.Trim(summary.Find("#warePriceId").Text(), " \t\n")
+		order.ShipPrice = strings.Trim(summary.Find("#freightPriceId").Text(), " \t\n")
+		jd.Logger.Info(jd.msg(msgOrderWarePrice), order.WarePrice)
+		jd.Logger.Info(jd.msg(msgOrderShipPrice), order.ShipPrice)
 
 	}
 
 	if sum := doc.Find("div.trade-foot").Eq(0); sum != nil {
-		payment := strings.Trim(sum.Find("#sumPayPriceId").Text(), " \t\n")
-		phone := strings.Trim(sum.Find("#sendMobile").Text(), " \t\n")
-		addr := strings.Trim(sum.Find("#sendAddr").Text(), " \t\n")
-		clog.Info("应付款: %s", payment)
-		clog.Info("%s", phone)
-		clog.Info("%s", addr)
+		order.Payment = strings.Trim(sum.Find("#sumPayPriceId").Text(), " \t\n")
+		order.Mobile = strings.Trim(sum.Find("#sendMobile").Text(), " \t\n")
+		order.Address = strings.Trim(sum.Find("#sendAddr").Text(), " \t\n")
+		jd.Logger.Info(jd.msg(msgOrderPayment), order.Payment)
+		jd.Logger.Info("%s", order.Mobile)
+		jd.Logger.Info("%s", order.Address)
 	}
 
-	return nil
+	return order, nil
 }
 
 // SubmitOrder ... submit order to JingDong, return orderID or error
 //
-func (jd *JingDong) SubmitOrder() (string, error) {
-	clog.Info(strSeperater)
-	clog.Info("提交订单>")
+func (jd *JingDong) SubmitOrder() (orderID string, err error) {
+	start := time.Now()
+	defer func() {
+		var data map[string]interface{}
+		if orderID != "" {
+			data = map[string]interface{}{"order_id": orderID}
+		}
+		jd.emit(PhaseSubmit, "", start, err, data)
+	}()
+
+	jd.Logger.Info(strSeperater)
+	jd.Logger.Info(jd.msg(msgSubmitTitle))
+
+	if err = jd.ensureSession(); err != nil {
+		jd.Logger.Error(jd.msg(msgSubmitNotLogin), err)
+		return "", err
+	}
+
+	// the order may be created even if the response is lost, so the orders
+	// and the goods in cart are recorded before submitting to tell the new
+	// order from the order list. The order is never submitted again after
+	// such a failure, the order list may lag behind.
+	known, gerr := jd.orderIDs()
+	var goods map[string]int
+	if gerr == nil {
+		goods, gerr = jd.selectedGoods()
+	}
+	if gerr != nil {
+		jd.Logger.Warn(jd.msg(msgSubmitGoodsUnknown), gerr)
+	}
+
+	if orderID, err = jd.submitOnce(); !submitUncertain(err) {
+		return orderID, err
+	}
+	if gerr != nil {
+		return "", orderStateUnknown(err, gerr)
+	}
 
+	wait := time.Second
+	for i := 0; ; i++ {
+		time.Sleep(wait)
+		found, cerr := jd.findOrder(known, goods)
+		if found != "" {
+			jd.Logger.Warn(jd.msg(msgSubmitFound), found)
+			return found, nil
+		}
+		if cerr == nil {
+			cerr = errOrderNotFound
+		}
+		if i >= jd.MaxRetries {
+			return "", orderStateUnknown(err, cerr)
+		}
+		jd.Logger.Warn(jd.msg(msgSubmitCheck), i+1, jd.MaxRetries, cerr)
+		wait *= 2
+	}
+}
+
+// submitOnce post the order to JD
+//
+func (jd *JingDong) submitOnce() (string, error) {
 	data, err := jd.getResponse("POST", URLSubmitOrder, func(URL string) string {
 		queryString := map[string]string{
 			"overseaPurchaseCookies":             "",
@@ -652,29 +854,29 @@ func (jd *JingDong) SubmitOrder() (string, error) {
 	})
 
 	if err != nil {
-		clog.Error(0, "提交订单失败: %+v", err)
+		jd.Logger.Error(jd.msg(msgSubmitFailed), err)
 		return "", err
 	}
 
 	var js *sjson.Json
 	if js, err = sjson.NewJson(data); err != nil {
-		clog.Info("Reponse Data: %s", data)
-		clog.Error(0, "无法解析订单响应数据: %+v", err)
-		return "", err
+		jd.Logger.Info("Reponse Data: %s", data)
+		jd.Logger.Error(jd.msg(msgSubmitParseFailed), err)
+		return "", newParseError("order", data, err)
 	}
 
-	clog.Trace("订单: %s", data)
+	jd.Logger.Trace("Order: %s", data)
 
 	if succ, _ := js.Get("success").Bool(); succ {
-		orderID, _ := js.Get("orderId").Int64()
-		clog.Info("下单成功，订单号：%d", orderID)
-		return fmt.Sprintf("%d", orderID), nil
+		id, _ := js.Get("orderId").Int64()
+		jd.Logger.Info(jd.msg(msgSubmitSuccess), id)
+		return fmt.Sprintf("%d", id), nil
 	}
 
 	res, _ := js.Get("resultCode").String()
 	msg, _ := js.Get("message").String()
-	clog.Error(0, "下单失败, %s : %s", res, msg)
-	return "", fmt.Errorf("failed to submit order (%s : %s)", res, msg)
+	jd.Logger.Error(jd.msg(msgSubmitRejected), res, msg)
+	return "", &OrderRejectedError{Code: res, Message: msg}
 }
 
 // wrap http get/post request
@@ -694,30 +896,34 @@ func (jd *JingDong) getResponse(method, URL string, queryFun func(URL string) st
 	if req, err = http.NewRequest(method, queryURL, nil); err != nil {
 		return nil, err
 	}
-	applyCustomHeader(req, DefaultHeaders)
 
 	if resp, err = jd.client.Do(req); err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
-	var reader io.Reader
+	if isLoginPage(resp.Request.URL.String()) {
+		// redirected to login page
+		return nil, ErrNotLoggedIn
+	}
 
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, _ = gzip.NewReader(resp.Body)
-	default:
-		reader = resp.Body
+	if resp.StatusCode != http.StatusOK {
+		jd.Logger.Error(jd.msg(msgHTTPStatus), resp.StatusCode, resp.Status)
+		serr := &HTTPStatusError{Code: resp.StatusCode, Status: resp.Status}
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			serr.RetryAfter = time.Duration(sec) * time.Second
+		}
+		return nil, serr
 	}
 
-	return ioutil.ReadAll(reader)
+	return responseData(resp)
 }
 
-// getPrice return sku price by ID
+// Price return sku price by ID
 //
 //  [{"id":"J_5105046","p":"1999.00","m":"9999.00","op":"1999.00","tpp":"1949.00"}]
 //
-func (jd *JingDong) getPrice(ID string) (string, error) {
+func (jd *JingDong) Price(ID string) (string, error) {
 	data, err := jd.getResponse("GET", URLGoodsPrice, func(URL string) string {
 		u, _ := url.Parse(URLGoodsPrice)
 		q := u.Query()
@@ -729,21 +935,25 @@ func (jd *JingDong) getPrice(ID string) (string, error) {
 	})
 
 	if err != nil {
-		clog.Error(0, "获取商品(%s)价格失败: %+v", ID, err)
+		jd.Logger.Error(jd.msg(msgPriceFailed), ID, err)
 		return "", err
 	}
 
 	var js *sjson.Json
 	if js, err = sjson.NewJson(data); err != nil {
-		clog.Info("Response Data: %s", data)
-		clog.Error(0, "解析响应数据失败: %+v", err)
-		return "", err
+		jd.Logger.Info("Response Data: %s", data)
+		jd.Logger.Error(jd.msg(msgParseRespFailed), err)
+		return "", newParseError("price", data, err)
 	}
 
-	return js.GetIndex(0).Get("p").String()
+	price, err := js.GetIndex(0).Get("p").String()
+	if err != nil {
+		return "", newParseError("price", data, err)
+	}
+	return price, nil
 }
 
-// stockState return stock state
+// StockState return stock state and state name of sku by ID
 // http://c0.3.cn/stock?skuId=531065&area=1_72_2799_0&cat=1,1,1&buyNum=1
 // http://c0.3.cn/stock?skuId=531065&area=1_72_2799_0&cat=1,1,1
 // https://c0.3.cn/stocks?type=getstocks&skuIds=4099139&area=1_72_2799_0&_=1499755881870
@@ -751,12 +961,35 @@ func (jd *JingDong) getPrice(ID string) (string, error) {
 // {"3133811":{"StockState":33,"freshEdi":null,"skuState":1,"PopType":0,"sidDely":"40",
 //	"channel":1,"StockStateName":"现货","rid":null,"rfg":0,"ArrivalDate":"",
 //  "IsPurchase":true,"rn":-1}}
-func (jd *JingDong) stockState(ID string) (string, string, error) {
+func (jd *JingDong) StockState(ID string) (string, string, error) {
+	stocks, err := jd.StockStates(ID)
+	if err != nil {
+		return "", "", err
+	}
+	return stocks[ID].State, stocks[ID].StateName, nil
+}
+
+// Stock is the stock state of a goods
+//
+type Stock struct {
+	State     string `json:"state"`      // 33 : on sale, 34 : out of stock
+	StateName string `json:"state_name"` // "现货" / "无货"
+}
+
+// StockStates return the stock states of goods by one request
+//
+func (jd *JingDong) StockStates(IDs ...string) (map[string]Stock, error) {
+	ids := strings.Join(IDs, ",")
+	if err := CheckArea(jd.ShipArea); err != nil {
+		jd.Logger.Error(jd.msg(msgStockFailed), ids, err)
+		return nil, err
+	}
+
 	data, err := jd.getResponse("GET", URLSKUState, func(URL string) string {
 		u, _ := url.Parse(URL)
 		q := u.Query()
 		q.Set("type", "getstocks")
-		q.Set("skuIds", ID)
+		q.Set("skuIds", ids)
 		q.Set("area", jd.ShipArea)
 		q.Set("_", strconv.FormatInt(time.Now().Unix()*1000, 10))
 		//q.Set("cat", "1,1,1")
@@ -766,50 +999,62 @@ func (jd *JingDong) stockState(ID string) (string, string, error) {
 	})
 
 	if err != nil {
-		clog.Error(0, "获取商品(%s)库存失败: %+v", ID, err)
-		return "", "", err
+		jd.Logger.Error(jd.msg(msgStockFailed), ids, err)
+		return nil, err
 	}
 
-	// return GBK encoding
-	dec := mahonia.NewDecoder("gbk")
-	decString := dec.ConvertString(string(data))
-	//clog.Trace(decString)
-
 	var js *sjson.Json
-	if js, err = sjson.NewJson([]byte(decString)); err != nil {
-		clog.Info("Response Data: %s", data)
-		clog.Error(0, "解析库存数据失败: %+v", err)
-		return "", "", err
+	if js, err = sjson.NewJson(data); err != nil {
+		jd.Logger.Info("Response Data: %s", data)
+		jd.Logger.Error(jd.msg(msgStockParseFailed), err)
+		return nil, newParseError("stock", data, err)
 	}
 
-	//if sku, exist := js.CheckGet("stock"); exist {
-	if sku, exist := js.CheckGet(ID); exist {
+	stocks := make(map[string]Stock, len(IDs))
+	for _, ID := range IDs {
+		sku, exist := js.CheckGet(ID)
+		if !exist {
+			return nil, newParseError("stock", data, nil)
+		}
 		skuState, _ := sku.Get("StockState").Int()
 		skuStateName, _ := sku.Get("StockStateName").String()
-		return strconv.Itoa(skuState), skuStateName, nil
+		stocks[ID] = Stock{State: strconv.Itoa(skuState), StateName: skuStateName}
 	}
-
-	return "", "", fmt.Errorf("无效响应数据")
+	return stocks, nil
 }
 
-// skuDetail get sku detail information
+// SKUDetail get sku detail information
 //
-func (jd *JingDong) skuDetail(ID string) (*SKUInfo, error) {
-	g := &SKUInfo{ID: ID}
+func (jd *JingDong) SKUDetail(ID string) (g *SKUInfo, err error) {
+	start := time.Now()
+	defer func() {
+		var data map[string]interface{}
+		if g != nil {
+			data = map[string]interface{}{
+				"name":       g.Name,
+				"price":      g.Price,
+				"state":      g.State,
+				"state_name": g.StateName,
+			}
+		}
+		jd.emit(PhaseSKUDetail, ID, start, err, data)
+	}()
+
+	g = &SKUInfo{ID: ID}
 
 	// response context encoding by GBK
 	//
 	itemURL := fmt.Sprintf("http://item.jd.com/%s.html", ID)
 	data, err := jd.getResponse("GET", itemURL, nil)
 	if err != nil {
-		clog.Error(0, "获取商品页面失败: %+v", err)
+		jd.Logger.Error(jd.msg(msgSKUPageFailed), err)
 		return nil, err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewBuffer(data))
 	if err != nil {
-		clog.Error(0, "解析商品页面失败: %+v", err)
-		return nil, err
+		jd.Logger.Error(jd.msg(msgSKUPageParseFailed), err)
+		return nil, newParseError("sku page", nil, err)
 	}
 
 	if link, exist := doc.Find("a#InitCartUrl").Attr("href"); exist {
@@ -819,35 +1064,54 @@ func (jd *JingDong) skuDetail(ID string) (*SKUInfo, error) {
 		}
 	}
 
-	dec := mahonia.NewDecoder("gbk")
-	//rd := dec.NewReader()
-
-	g.Name = strings.Trim(dec.ConvertString(doc.Find("div.sku-name").Text()), " \t\n")
+	g.Name = strings.Trim(doc.Find("div.sku-name").Text(), " \t\n")
 	g.Name = truncate(g.Name)
 
-	g.Price, _ = jd.getPrice(ID)
-	g.State, g.StateName, _ = jd.stockState(ID)
+	// pageConfig = { product: { ..., venderId:1000000127, ... } }
+	if m := reVenderID.FindSubmatch(data); m != nil {
+		g.VenderID = string(m[1])
+	}
+	g.Limit = parseLimit(string(data))
+
+	if g.Price, err = jd.Price(ID); err != nil {
+		return nil, err
+	}
+	if g.State, g.StateName, err = jd.StockState(ID); err != nil {
+		return nil, err
+	}
 
 	//info := fmt.Sprintf("编号: %s, 库存: %s, 价格: %s, 链接: %s", g.ID, g.StateName, g.Price, g.Link)
-	//clog.Info(info)
+	//jd.Logger.Info(info)
 
-	clog.Info(strSeperater)
-	clog.Info("商品详情>")
-	clog.Info("编号: %s, 库存: %s, 价格: %s", g.ID, g.StateName, g.Price)
+	jd.Logger.Info(strSeperater)
+	jd.Logger.Info(jd.msg(msgSKUTitle))
+	jd.Logger.Info(jd.msg(msgSKUDetail), g.ID, g.StateName, g.Price)
 
 	return g, nil
 }
 
-func (jd *JingDong) changeCount(ID string, count int) (int, error) {
+// changeCount change the count of goods in cart, the vender, promotion
+// and target ID of goods are required by POP (third-party) sellers and
+// promotion bundles, missing ones default to JD self-operated without
+// promotion.
+//
+//  {"pcount":2,"pid":"531065","sortedWebCartResult":{...}}
+//
+func (jd *JingDong) changeCount(sku *SKUInfo, count int) (*CountResult, error) {
+	if err := CheckArea(jd.ShipArea); err != nil {
+		jd.Logger.Error(jd.msg(msgChangeCountFailed), err)
+		return nil, err
+	}
+
 	data, err := jd.getResponse("POST", URLChangeCount, func(URL string) string {
 		u, _ := url.Parse(URL)
 		q := u.Query()
-		q.Set("venderId", "8888")
-		q.Set("targetId", "0")
-		q.Set("promoID", "0")
+		q.Set("venderId", orDefault(sku.VenderID, venderJD))
+		q.Set("targetId", orDefault(sku.TargetID, "0"))
+		q.Set("promoID", orDefault(sku.PromoID, "0"))
 		q.Set("outSkus", "")
 		q.Set("ptype", "1")
-		q.Set("pid", ID)
+		q.Set("pid", sku.ID)
 		q.Set("pcount", strconv.Itoa(count))
 		q.Set("random", strconv.FormatFloat(rand.Float64(), 'f', 16, 64))
 		q.Set("locationId", jd.ShipArea)
@@ -856,34 +1120,169 @@ func (jd *JingDong) changeCount(ID string, count int) (int, error) {
 	})
 
 	if err != nil {
-		clog.Error(0, "修改商品数量失败: %+v", err)
-		return 0, err
+		jd.Logger.Error(jd.msg(msgChangeCountFailed), err)
+		return nil, err
+	}
+
+	var js *sjson.Json
+	if js, err = sjson.NewJson(data); err != nil {
+		return nil, newParseError("change count", data, err)
 	}
 
-	js, _ := sjson.NewJson(data)
-	return js.Get("pcount").Int()
+	res := &CountResult{ID: sku.ID, Requested: count}
+	if res.Count, err = js.Get("pcount").Int(); err != nil {
+		return nil, newParseError("change count", data, err)
+	}
+
+	if res.Count != count {
+		jd.Logger.Warn(jd.msg(msgCountClamped), sku.ID, count, res.Count)
+		return res, &ItemError{ID: sku.ID, Err: &CountClampedError{ID: sku.ID, Requested: count, Count: res.Count}}
+	}
+	return res, nil
 }
 
-func (jd *JingDong) buyGood(sku *SKUInfo) error {
-	var (
-		err  error
-		data []byte
-		doc  *goquery.Document
-	)
+// cartParams fill the vender, promotion and target ID of goods from cart
+//
+func (jd *JingDong) cartParams(sku *SKUInfo) {
+	if sku.PromoID != "" && sku.VenderID != "" {
+		return
+	}
+
+	cart, err := jd.CartDetails()
+	if err != nil {
+		return
+	}
+	if item := cart.Item(sku.ID); item != nil {
+		sku.VenderID = orDefault(item.VenderID, sku.VenderID)
+		sku.PromoID = orDefault(item.PromoID, sku.PromoID)
+		sku.TargetID = orDefault(item.TargetID, sku.TargetID)
+	}
+}
 
+// cartItem return the goods in cart with the IDs filled by cartParams
+//
+func (sku *SKUInfo) cartItem() *CartItem {
+	return &CartItem{
+		ID:       sku.ID,
+		Count:    sku.Count,
+		VenderID: sku.VenderID,
+		PromoID:  sku.PromoID,
+		TargetID: sku.TargetID,
+	}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// waitStock refresh the stock state until the goods is in stock, return
+// the count of polls
+//
+func (jd *JingDong) waitStock(sku *SKUInfo, run *rushRun) (int, error) {
 	// 33 : on sale
 	// 34 : out of stock
 	// 库存状态还有一种是采购中，但是依然可以下单，state 未知
-	for sku.State == "34" && jd.AutoRush {
-		clog.Warn("%s : %s", sku.StateName, sku.Name)
-		time.Sleep(jd.Period)
-		sku.State, sku.StateName, err = jd.stockState(sku.ID)
-		if err != nil {
-			clog.Error(0, "获取(%s)库存失败: %+v", sku.ID, err)
-			return err
+	if sku.State == "34" && !jd.AutoRush {
+		jd.Logger.Warn("%s : %s", sku.StateName, sku.Name)
+		return 0, &ItemError{ID: sku.ID, Err: ErrOutOfStock}
+	}
+
+	p := &poller{jd: jd, ID: sku.ID, deadline: run.deadline}
+	for sku.State == "34" {
+		jd.Logger.Warn("%s : %s", sku.StateName, sku.Name)
+		if err := p.wait(); err != nil {
+			return p.poll, err
+		}
+
+		release := run.acquire()
+		start := time.Now()
+		state, name, err := jd.StockState(sku.ID)
+		release()
+		if err == nil {
+			sku.State, sku.StateName = state, name
 		}
+		jd.emit(PhaseStockPoll, sku.ID, start, err, map[string]interface{}{
+			"poll":       p.poll,
+			"state":      sku.State,
+			"state_name": sku.StateName,
+		})
+
+		if err = p.done(err); err != nil {
+			jd.Logger.Error(jd.msg(msgStockFailed), sku.ID, err)
+			return p.poll, err
+		}
+	}
+	return p.poll, nil
+}
+
+// checkPrice refresh the price of sku and check it against MaxPrice
+//
+func (jd *JingDong) checkPrice(sku *SKUInfo) error {
+	if sku.MaxPrice <= 0 {
+		return nil
+	}
+
+	price, err := jd.Price(sku.ID)
+	if err != nil {
+		return err
+	}
+	sku.Price = price
+
+	if p, err := parseAmount(price); err != nil {
+		return newParseError("price", []byte(price), err)
+	} else if p > sku.MaxPrice {
+		jd.Logger.Warn(jd.msg(msgPriceExceeded), sku.ID, price, sku.MaxPrice)
+		return &ItemError{ID: sku.ID, Err: fmt.Errorf("%s > %.2f: %w", price, sku.MaxPrice, ErrPriceExceeded)}
+	}
+	return nil
+}
+
+// checkPayment check the payment of order against MaxPayment
+//
+func (jd *JingDong) checkPayment(order *OrderPreview) error {
+	if jd.MaxPayment <= 0 {
+		return nil
 	}
 
+	p, err := parseAmount(order.Payment)
+	if err != nil {
+		return newParseError("payment", []byte(order.Payment), err)
+	}
+	if p > jd.MaxPayment {
+		jd.Logger.Warn(jd.msg(msgPaymentExceeded), order.Payment, jd.MaxPayment)
+		return fmt.Errorf("payment %s > %.2f: %w", order.Payment, jd.MaxPayment, ErrPriceExceeded)
+	}
+	return nil
+}
+
+// parseAmount parse money string like "￥1,999.00"
+//
+func parseAmount(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimLeft(s, "￥¥ ")
+	s = strings.Replace(s, ",", "", -1)
+	return strconv.ParseFloat(s, 64)
+}
+
+// addToCart add the sku to cart and change the count if needed
+//
+func (jd *JingDong) addToCart(sku *SKUInfo) (err error) {
+	var (
+		data  []byte
+		doc   *goquery.Document
+		count int
+	)
+
+	start := time.Now()
+	defer func() {
+		jd.emit(PhaseAddToCart, sku.ID, start, err, map[string]interface{}{
+			"count": count,
+		})
+	}()
+
 	if sku.Link == "" || sku.Count != 1 {
 		u, _ := url.Parse(URLAdd2Cart)
 		q := u.Query()
@@ -893,21 +1292,21 @@ func (jd *JingDong) buyGood(sku *SKUInfo) error {
 		u.RawQuery = q.Encode()
 		sku.Link = u.String()
 	}
-	clog.Info("购买链接: %s", sku.Link)
+	jd.Logger.Info(jd.msg(msgCartLink), sku.Link)
 
 	if _, err := url.Parse(sku.Link); err != nil {
-		clog.Error(0, "商品购买链接无效: <%s>", sku.Link)
-		return fmt.Errorf("无效商品购买链接<%s>", sku.Link)
+		jd.Logger.Error(jd.msg(msgCartLinkInvalid), sku.Link)
+		return fmt.Errorf("invalid cart link <%s>: %w", sku.Link, err)
 	}
 
 	if data, err = jd.getResponse("GET", sku.Link, nil); err != nil {
-		clog.Error(0, "商品(%s)购买失败: %+v", sku.ID, err)
+		jd.Logger.Error(jd.msg(msgBuyFailed), sku.ID, err)
 		return err
 	}
 
 	if doc, err = goquery.NewDocumentFromReader(bytes.NewBuffer(data)); err != nil {
-		clog.Error(0, "响应解析失败: %+v", err)
-		return err
+		jd.Logger.Error(jd.msg(msgParseRespFailed), err)
+		return newParseError("add to cart", data, err)
 	}
 
 	succFlag := doc.Find("h3.ftx-02").Text()
@@ -917,51 +1316,37 @@ func (jd *JingDong) buyGood(sku *SKUInfo) error {
 		succFlag = doc.Find("div.p-name a").Text()
 	}
 
-	if succFlag != "" {
-		count := 0
-		if sku.Count > 1 {
-			count, err = jd.changeCount(sku.ID, sku.Count)
-		}
-
-		if count > 0 {
-			clog.Info("购买结果：成功加入进购物车 [%d] 个 [%s]", count, sku.Name)
-			return nil
-		}
+	if succFlag == "" {
+		jd.Logger.Error(jd.msg(msgAddCartFailed), sku.ID)
+		return &ItemError{ID: sku.ID, Err: ErrAddToCart}
 	}
 
-	return err
-}
+	count = sku.Count
+	if sku.Count > 1 {
+		jd.cartParams(sku)
 
-// 支持多件商品抢购直接下单
-func (jd *JingDong) RushBuy(skuLst map[string]int) {
-	// 主协程结束堵塞 channel
-	endChannel := make(chan int, len(skuLst))
-	// 提交订单另外开一个 worker 执行，此为执行队列
-	submitOrderSemp := make(chan int)
+		var res *CountResult
+		res, err = jd.changeCount(sku, sku.Count)
+		if res != nil {
+			count = res.Count
+		}
 
-	for id, cnt := range skuLst {
-		go func(id string, count int) {
-			if sku, err := jd.skuDetail(id); err == nil {
-				sku.Count = count
-				jd.buyGood(sku)
-				jd.OrderInfo()
-				if jd.AutoSubmit {
-					// jd.SubmitOrder()
-					submitOrderSemp <- 1
+		// JD clamps the count to the purchase limit
+		if errors.Is(err, ErrCountClamped) && res.Count < sku.Count {
+			if err = jd.applyLimit(sku, res.Count); err != nil {
+				// do not order the goods added
+				if cerr := jd.itemAction(URLCancelItem, []*CartItem{sku.cartItem()}); cerr != nil {
+					jd.Logger.Warn(jd.msg(msgLimitUnselectFailed), sku.ID, cerr)
+					return fmt.Errorf("%w; unselect the goods added: %v", err, cerr)
 				}
+				return err
 			}
-		}(id, cnt)
-	}
-	// submitOrder worker
-	go func() {
-		for {
-			<-submitOrderSemp
-			jd.SubmitOrder()
-			endChannel <- 1
-			time.Sleep(time.Millisecond * 1000)
 		}
-	}()
-	for i := 0; i < len(skuLst); i++ {
-		<-endChannel
+		if err != nil {
+			return err
+		}
 	}
+
+	jd.Logger.Info(jd.msg(msgAddCartSuccess), count, sku.Name)
+	return nil
 }
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// LimitPolicy decide what to do when the buying count exceeds the purchase
+// limit of the account
+//
+type LimitPolicy string
+
+const (
+	// LimitFail refuse to buy the goods, it is the default
+	LimitFail LimitPolicy = "fail"
+
+	// LimitClamp buy the goods with the count allowed
+	LimitClamp LimitPolicy = "clamp"
+
+	// LimitSplit buy the goods with the count allowed, the rest is left to
+	// the other profiles, see SplitItems
+	LimitSplit LimitPolicy = "split"
+)
+
+// ParseLimitPolicy return the policy by name, empty for LimitFail
+//
+func ParseLimitPolicy(s string) (LimitPolicy, error) {
+	switch p := LimitPolicy(s); p {
+	case "":
+		return LimitFail, nil
+	case LimitFail, LimitClamp, LimitSplit:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown limit policy %q, want fail, clamp or split", s)
+}
+
+// PurchaseLimitError carries the count requested and the count allowed by
+// the purchase limit. It matches ErrPurchaseLimit with errors.Is.
+//
+type PurchaseLimitError struct {
+	ID        string
+	Requested int
+	Allowed   int
+}
+
+func (e *PurchaseLimitError) Error() string {
+	return fmt.Sprintf("%s: requested %d, allowed %d", ErrPurchaseLimit, e.Requested, e.Allowed)
+}
+
+// Is report whether target is ErrPurchaseLimit
+//
+func (e *PurchaseLimitError) Is(target error) bool {
+	return target == ErrPurchaseLimit
+}
+
+// 每人限购2件 / 限购2件
+var reLimit = regexp.MustCompile(`限购\s*(\d+)\s*件`)
+
+// parseLimit return the purchase limit from the sku page, 0 means no limit
+//
+func parseLimit(page string) int {
+	if m := reLimit.FindStringSubmatch(page); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// checkLimit apply the limit policy before adding the goods to cart
+//
+func (jd *JingDong) checkLimit(sku *SKUInfo) error {
+	if sku.Limit <= 0 || sku.Count <= sku.Limit {
+		return nil
+	}
+	return jd.applyLimit(sku, sku.Limit)
+}
+
+// applyLimit clamp the count of sku to allowed, or return
+// PurchaseLimitError by the limit policy
+//
+func (jd *JingDong) applyLimit(sku *SKUInfo, allowed int) error {
+	jd.Logger.Warn(jd.msg(msgPurchaseLimit), sku.ID, sku.Count, allowed)
+
+	if jd.LimitPolicy == LimitFail || jd.LimitPolicy == "" || allowed <= 0 {
+		return &ItemError{ID: sku.ID, Err: &PurchaseLimitError{ID: sku.ID, Requested: sku.Count, Allowed: allowed}}
+	}
+	sku.Limit = allowed
+	sku.Count = allowed
+	return nil
+}
+
+// SplitItems split the goods across n profiles, so that each profile buys
+// no more than the purchase limit of goods, limit return 0 for no limit
+// or unknown. The goods without limit or with alternatives are bought by
+// the first profile as a whole. It returns PurchaseLimitError if the count
+// exceeds the limit of all profiles.
+//
+func SplitItems(items []RushItem, n int, limit func(ID string) int) ([][]RushItem, error) {
+	if n < 1 {
+		return nil, errors.New("no profile to split the goods")
+	}
+
+	parts := make([][]RushItem, n)
+	for _, item := range items {
+		if item.Count < 1 {
+			item.Count = 1
+		}
+		max := limit(item.ID)
+		if max <= 0 || len(item.Alternatives) > 0 {
+			parts[0] = append(parts[0], item)
+			continue
+		}
+		if item.Count > max*n {
+			return nil, &ItemError{ID: item.ID, Err: &PurchaseLimitError{ID: item.ID, Requested: item.Count, Allowed: max * n}}
+		}
+
+		for i, left := 0, item.Count; left > 0; i++ {
+			part := item
+			if part.Count = left; part.Count > max {
+				part.Count = max
+			}
+			parts[i] = append(parts[i], part)
+			left -= part.Count
+		}
+	}
+	return parts, nil
+}
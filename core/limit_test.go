@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		page string
+		want int
+	}{
+		{"", 0},
+		{"<div>每人限购2件</div>", 2},
+		{"限购 5 件", 5},
+		{"限购10件，超出部分无法下单", 10},
+		{"限购件", 0},
+		{"限购2个", 0},
+		{"限购99999999999999999999件", 0},
+	}
+	for _, tt := range tests {
+		if got := parseLimit(tt.page); got != tt.want {
+			t.Errorf("parseLimit(%q) = %d, want %d", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestSplitItems(t *testing.T) {
+	limits := map[string]int{"1": 2, "2": 3, "3": 0, "4": 1}
+	limit := func(ID string) int { return limits[ID] }
+
+	tests := []struct {
+		name  string
+		items []RushItem
+		n     int
+		want  [][]RushItem
+	}{
+		{
+			name:  "within limit",
+			items: []RushItem{{ID: "1", Count: 2}},
+			n:     2,
+			want:  [][]RushItem{{{ID: "1", Count: 2}}, nil},
+		},
+		{
+			name:  "remainder",
+			items: []RushItem{{ID: "2", Count: 7}},
+			n:     3,
+			want:  [][]RushItem{{{ID: "2", Count: 3}}, {{ID: "2", Count: 3}}, {{ID: "2", Count: 1}}},
+		},
+		{
+			name:  "exact",
+			items: []RushItem{{ID: "1", Count: 4}, {ID: "4", Count: 2}},
+			n:     2,
+			want: [][]RushItem{
+				{{ID: "1", Count: 2}, {ID: "4", Count: 1}},
+				{{ID: "1", Count: 2}, {ID: "4", Count: 1}},
+			},
+		},
+		{
+			name:  "unknown limit",
+			items: []RushItem{{ID: "3", Count: 9}, {ID: "5", Count: 3}},
+			n:     2,
+			want:  [][]RushItem{{{ID: "3", Count: 9}, {ID: "5", Count: 3}}, nil},
+		},
+		{
+			name:  "alternatives",
+			items: []RushItem{{ID: "1", Count: 3, Alternatives: []RushItem{{ID: "3"}}}},
+			n:     2,
+			want:  [][]RushItem{{{ID: "1", Count: 3, Alternatives: []RushItem{{ID: "3"}}}}, nil},
+		},
+		{
+			name:  "zero count",
+			items: []RushItem{{ID: "1"}},
+			n:     2,
+			want:  [][]RushItem{{{ID: "1", Count: 1}}, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := SplitItems(tt.items, tt.n, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parts, tt.want) {
+				t.Errorf("SplitItems = %+v, want %+v", parts, tt.want)
+			}
+
+			// nothing is dropped
+			for _, item := range tt.items {
+				var total int
+				for _, part := range parts {
+					for _, it := range part {
+						if it.ID == item.ID {
+							total += it.Count
+						}
+					}
+				}
+				if want := item.Count; total != want && !(want == 0 && total == 1) {
+					t.Errorf("count of %s = %d, want %d", item.ID, total, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitItemsExceeded(t *testing.T) {
+	_, err := SplitItems([]RushItem{{ID: "1", Count: 5}}, 2, func(string) int { return 2 })
+	var le *PurchaseLimitError
+	if !errors.As(err, &le) || !errors.Is(err, ErrPurchaseLimit) {
+		t.Fatalf("SplitItems: %v, want PurchaseLimitError", err)
+	}
+	if le.Requested != 5 || le.Allowed != 4 {
+		t.Errorf("error = %+v, want requested 5 and allowed 4", le)
+	}
+}
+
+func TestSplitItemsNoProfile(t *testing.T) {
+	if _, err := SplitItems([]RushItem{{ID: "1", Count: 1}}, 0, func(string) int { return 0 }); err == nil {
+		t.Error("SplitItems with no profile succeeded, want error")
+	}
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	clog "gopkg.in/clog.v1"
+)
+
+// Logger is used by JingDong to write logs, set JDConfig.Logger to plug in
+// the logging package of the application. The messages are printf style.
+//
+type Logger interface {
+	Trace(format string, v ...interface{})
+	Info(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+}
+
+// NopLogger discards all logs, it is the default of JDConfig.Logger
+//
+type NopLogger struct{}
+
+func (NopLogger) Trace(format string, v ...interface{}) {}
+func (NopLogger) Info(format string, v ...interface{})  {}
+func (NopLogger) Warn(format string, v ...interface{})  {}
+func (NopLogger) Error(format string, v ...interface{}) {}
+
+// ClogLogger write logs by the global clog logger, the application should
+// initialize clog by clog.New before use.
+//
+type ClogLogger struct{}
+
+func (ClogLogger) Trace(format string, v ...interface{}) { clog.Trace(format, v...) }
+func (ClogLogger) Info(format string, v ...interface{})  { clog.Info(format, v...) }
+func (ClogLogger) Warn(format string, v ...interface{})  { clog.Warn(format, v...) }
+func (ClogLogger) Error(format string, v ...interface{}) { clog.Error(0, format, v...) }
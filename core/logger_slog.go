@@ -0,0 +1,42 @@
+//go:build go1.21
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LevelTrace is the slog level used for Trace logs
+//
+const LevelTrace = slog.LevelDebug - 4
+
+// SlogLogger adapt *slog.Logger to Logger
+//
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger return a Logger which writes to l, slog.Default() is used
+// if l is nil.
+//
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{logger: l}
+}
+
+func (l *SlogLogger) Trace(format string, v ...interface{}) { l.logf(LevelTrace, format, v...) }
+func (l *SlogLogger) Info(format string, v ...interface{})  { l.logf(slog.LevelInfo, format, v...) }
+func (l *SlogLogger) Warn(format string, v ...interface{})  { l.logf(slog.LevelWarn, format, v...) }
+func (l *SlogLogger) Error(format string, v ...interface{}) { l.logf(slog.LevelError, format, v...) }
+
+func (l *SlogLogger) logf(level slog.Level, format string, v ...interface{}) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.Log(ctx, level, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,467 @@
+package core
+
+import (
+	"os"
+	"strings"
+)
+
+// Lang is the language of the log messages
+//
+type Lang string
+
+// Supported languages
+//
+const (
+	LangZhCN Lang = "zh-CN"
+	LangEnUS Lang = "en-US"
+)
+
+// DefaultLang is used when the language is not set or not supported
+//
+var DefaultLang = LangZhCN
+
+// ParseLang return the Lang from a language tag or locale, e.g. "en",
+// "en-US", "en_US.UTF-8", "zh_CN.GBK". DefaultLang is returned if it is
+// not supported.
+//
+func ParseLang(s string) Lang {
+	s = strings.ToLower(s)
+	if n := strings.IndexAny(s, ".@"); n >= 0 {
+		s = s[:n]
+	}
+	s = strings.Replace(s, "_", "-", -1)
+
+	switch {
+	case s == "en" || strings.HasPrefix(s, "en-"):
+		return LangEnUS
+	case s == "zh" || strings.HasPrefix(s, "zh-"):
+		return LangZhCN
+	}
+	return DefaultLang
+}
+
+// EnvLang return the Lang from environment variable LC_ALL, LC_MESSAGES
+// or LANG in order.
+//
+func EnvLang() Lang {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" && v != "C" && v != "POSIX" {
+			return ParseLang(v)
+		}
+	}
+	return DefaultLang
+}
+
+// MsgID identify a message in the catalog
+//
+type MsgID string
+
+// message IDs used by the package
+//
+const (
+	msgRequestFailed       MsgID = "request.failed"
+	msgReadRespFailed      MsgID = "response.read_failed"
+	msgParseRespFailed     MsgID = "response.parse_failed"
+	msgHTTPStatus          MsgID = "response.status"
+	msgLoadCookiesFailed   MsgID = "cookies.load_failed"
+	msgSaveCookiesFailed   MsgID = "cookies.save_failed"
+	msgLoginPageFailed     MsgID = "login.page_failed"
+	msgQRDownloadFailed    MsgID = "login.qr_download_failed"
+	msgQRSaveFailed        MsgID = "login.qr_save_failed"
+	msgQRInvalid           MsgID = "login.qr_invalid"
+	msgQRNoResult          MsgID = "login.qr_no_result"
+	msgQRValidateFailed    MsgID = "login.qr_validate_failed"
+	msgRiskVerify          MsgID = "login.risk_verify"
+	msgLoginFailedCode     MsgID = "login.failed_code"
+	msgLoginFailed         MsgID = "login.failed"
+	msgLoginSuccess        MsgID = "login.success"
+	msgOpenVerifyFailed    MsgID = "login.open_verify_failed"
+	msgWaitVerify          MsgID = "login.wait_verify"
+	msgWaitVerifyTimeout   MsgID = "login.wait_verify_timeout"
+	msgAlreadyLogin        MsgID = "login.already"
+	msgScanQR              MsgID = "login.scan_qr"
+	msgOpenQRFailed        MsgID = "login.open_qr_failed"
+	msgNeedLogin           MsgID = "session.need_login"
+	msgNeedLoginErr        MsgID = "session.need_login_err"
+	msgNeedLoginRedirect   MsgID = "session.need_login_redirect"
+	msgNeedLoginStatus     MsgID = "session.need_login_status"
+	msgNeedLoginNoUser     MsgID = "session.need_login_no_user"
+	msgKeeperStarted       MsgID = "session.keeper_started"
+	msgSessionExpiring     MsgID = "session.expiring"
+	msgSessionExpired      MsgID = "session.expired"
+	msgReloginFailed       MsgID = "session.relogin_failed"
+	msgRefreshFailed       MsgID = "session.refresh_failed"
+	msgSessionRefreshed    MsgID = "session.refreshed"
+	msgNotifyFailed        MsgID = "notify.failed"
+	msgNotifyExpiring      MsgID = "notify.session_expiring"
+	msgNotifyExpired       MsgID = "notify.session_expired"
+	msgCartTitle           MsgID = "cart.title"
+	msgCartFailed          MsgID = "cart.failed"
+	msgCartParseFailed     MsgID = "cart.parse_failed"
+	msgCartHeader          MsgID = "cart.header"
+	msgCartTotalCount      MsgID = "cart.total_count"
+	msgCartTotalValue      MsgID = "cart.total_value"
+	msgOrderTitle          MsgID = "order.title"
+	msgOrderFailed         MsgID = "order.failed"
+	msgOrderParseFailed    MsgID = "order.parse_failed"
+	msgOrderWarePrice      MsgID = "order.ware_price"
+	msgOrderShipPrice      MsgID = "order.ship_price"
+	msgOrderPayment        MsgID = "order.payment"
+	msgSubmitTitle         MsgID = "submit.title"
+	msgSubmitNotLogin      MsgID = "submit.not_login"
+	msgSubmitFailed        MsgID = "submit.failed"
+	msgSubmitParseFailed   MsgID = "submit.parse_failed"
+	msgSubmitSuccess       MsgID = "submit.success"
+	msgSubmitRejected      MsgID = "submit.rejected"
+	msgPriceFailed         MsgID = "sku.price_failed"
+	msgStockFailed         MsgID = "sku.stock_failed"
+	msgStockParseFailed    MsgID = "sku.stock_parse_failed"
+	msgSKUPageFailed       MsgID = "sku.page_failed"
+	msgSKUPageParseFailed  MsgID = "sku.page_parse_failed"
+	msgSKUTitle            MsgID = "sku.title"
+	msgSKUDetail           MsgID = "sku.detail"
+	msgChangeCountFailed   MsgID = "cart.change_count_failed"
+	msgCartLink            MsgID = "cart.link"
+	msgCartLinkInvalid     MsgID = "cart.link_invalid"
+	msgBuyFailed           MsgID = "cart.buy_failed"
+	msgAddCartFailed       MsgID = "cart.add_failed"
+	msgAddCartSuccess      MsgID = "cart.add_success"
+	msgDeadlinePassed      MsgID = "rush.deadline_passed"
+	msgPriceExceeded       MsgID = "rush.price_exceeded"
+	msgPaymentExceeded     MsgID = "rush.payment_exceeded"
+	msgAddressFailed       MsgID = "address.failed"
+	msgAddressParseFailed  MsgID = "address.parse_failed"
+	msgAddressNotFound     MsgID = "address.not_found"
+	msgAddressSelectFailed MsgID = "address.select_failed"
+	msgAddressSelected     MsgID = "address.selected"
+	msgAddressNoArea       MsgID = "address.no_area"
+	msgCartActionFailed    MsgID = "cart.action_failed"
+	msgLimitUnselectFailed MsgID = "cart.limit_unselect_failed"
+	msgCountClamped        MsgID = "cart.count_clamped"
+	msgPurchaseLimit       MsgID = "cart.purchase_limit"
+	msgOrderPolicy         MsgID = "rush.order_policy"
+	msgFallback            MsgID = "rush.fallback"
+	msgGroupSkip           MsgID = "rush.group_skip"
+	msgPollRetry           MsgID = "rush.poll_retry"
+	msgSubmitCheck         MsgID = "submit.check"
+	msgSubmitFound         MsgID = "submit.found"
+	msgOrderListFailed     MsgID = "order_list.failed"
+	msgProxyInvalid        MsgID = "proxy.invalid"
+	msgSubmitGoodsUnknown  MsgID = "submit.goods_unknown"
+)
+
+// message IDs of the tables printed by autobuy, the columns of a table
+// header are separated by tab
+//
+const (
+	MsgTableCart     MsgID = "table.cart"
+	MsgTableReport   MsgID = "table.report"
+	MsgTableRates    MsgID = "table.rates"
+	MsgTableAddress  MsgID = "table.address"
+	MsgTableStock    MsgID = "table.stock"
+	MsgTablePrice    MsgID = "table.price"
+	MsgTableArea     MsgID = "table.area"
+	MsgLabelProfile  MsgID = "label.profile"
+	MsgLabelSelected MsgID = "label.selected"
+	MsgLabelTotal    MsgID = "label.total"
+	MsgLabelGoods    MsgID = "label.goods"
+	MsgLabelShipping MsgID = "label.shipping"
+	MsgLabelPayment  MsgID = "label.payment"
+	MsgLabelMobile   MsgID = "label.mobile"
+	MsgLabelAddress  MsgID = "label.address"
+	MsgLabelPreview  MsgID = "label.preview"
+	MsgLabelSubmit   MsgID = "label.submit"
+	MsgLabelOrder    MsgID = "label.order"
+	MsgLabelError    MsgID = "label.error"
+	MsgLabelElapsed  MsgID = "label.elapsed"
+	MsgLabelUser     MsgID = "label.user"
+	MsgLabelNick     MsgID = "label.nick"
+	MsgLabelLogin    MsgID = "label.login"
+	MsgLabelExpires  MsgID = "label.expires"
+	MsgLabelID       MsgID = "label.id"
+	MsgLabelName     MsgID = "label.name"
+	MsgLabelPrice    MsgID = "label.price"
+	MsgLabelStock    MsgID = "label.stock"
+	MsgLabelLink     MsgID = "label.link"
+	MsgLabelVender   MsgID = "label.vender"
+)
+
+// catalog holds the message formats of each language
+//
+var catalog = map[Lang]map[MsgID]string{
+	LangZhCN: {
+		msgRequestFailed:       "请求(%+v)失败: %+v",
+		msgReadRespFailed:      "读取响应数据失败: %+v",
+		msgParseRespFailed:     "解析响应数据失败: %+v",
+		msgHTTPStatus:          "http status : %d/%s",
+		msgLoadCookiesFailed:   "加载Cookies失败: %s",
+		msgSaveCookiesFailed:   "保存Cookies失败: %+v",
+		msgLoginPageFailed:     "请求登录页失败: %+v",
+		msgQRDownloadFailed:    "下载二维码失败: %+v",
+		msgQRSaveFailed:        "保存二维码失败: %+v",
+		msgQRInvalid:           "二维码失效：%+v",
+		msgQRNoResult:          "未检测到QR扫码结果",
+		msgQRValidateFailed:    "二维码登陆校验失败: %+v",
+		msgRiskVerify:          "安全验证: %s",
+		msgLoginFailedCode:     "登陆失败, 返回码: %d",
+		msgLoginFailed:         "登陆失败",
+		msgLoginSuccess:        "登陆成功, P3P: %s",
+		msgOpenVerifyFailed:    "打开安全验证页面失败: %+v",
+		msgWaitVerify:          "请在 %v 内完成安全验证",
+		msgWaitVerifyTimeout:   "等待安全验证超时",
+		msgAlreadyLogin:        "无需重新登录, 用户: %s",
+		msgScanQR:              "请打开京东手机客户端，准备扫码登陆:",
+		msgOpenQRFailed:        "打开二维码图片失败: %+v.",
+		msgNeedLogin:           "需要重新登录",
+		msgNeedLoginErr:        "需要重新登录: %+v",
+		msgNeedLoginRedirect:   "需要重新登录, 跳转: %s",
+		msgNeedLoginStatus:     "需要重新登录, %s",
+		msgNeedLoginNoUser:     "需要重新登录, 未找到用户信息",
+		msgKeeperStarted:       "会话保持已开启, 间隔: %v",
+		msgSessionExpiring:     "登录即将过期: %s",
+		msgSessionExpired:      "登录已失效, 重新登录",
+		msgReloginFailed:       "重新登录失败: %+v",
+		msgRefreshFailed:       "刷新会话失败: %+v",
+		msgSessionRefreshed:    "会话已刷新",
+		msgNotifyFailed:        "发送通知失败: %+v",
+		msgNotifyExpiring:      "京东登录即将过期, 请重新扫码登录",
+		msgNotifyExpired:       "京东登录已失效, 请重新扫码登录",
+		msgCartTitle:           "购物车详情>",
+		msgCartFailed:          "获取购物车详情错误: %+v",
+		msgCartParseFailed:     "分析购物车页面错误: %+v.",
+		msgCartHeader:          "购买  数量  价格      总价      编号        商品",
+		msgCartTotalCount:      "总数: %s",
+		msgCartTotalValue:      "总额: %s",
+		msgOrderTitle:          "订单详情>",
+		msgOrderFailed:         "获取订单页错误: %+v",
+		msgOrderParseFailed:    "分析订单页错误: %+v.",
+		msgOrderWarePrice:      "总金额: %s",
+		msgOrderShipPrice:      "　运费: %s",
+		msgOrderPayment:        "应付款: %s",
+		msgSubmitTitle:         "提交订单>",
+		msgSubmitNotLogin:      "登录失效, 无法提交订单: %+v",
+		msgSubmitFailed:        "提交订单失败: %+v",
+		msgSubmitParseFailed:   "无法解析订单响应数据: %+v",
+		msgSubmitSuccess:       "下单成功，订单号：%d",
+		msgSubmitRejected:      "下单失败, %s : %s",
+		msgPriceFailed:         "获取商品(%s)价格失败: %+v",
+		msgStockFailed:         "获取商品(%s)库存失败: %+v",
+		msgStockParseFailed:    "解析库存数据失败: %+v",
+		msgSKUPageFailed:       "获取商品页面失败: %+v",
+		msgSKUPageParseFailed:  "解析商品页面失败: %+v",
+		msgSKUTitle:            "商品详情>",
+		msgSKUDetail:           "编号: %s, 库存: %s, 价格: %s",
+		msgChangeCountFailed:   "修改商品数量失败: %+v",
+		msgCartLink:            "购买链接: %s",
+		msgCartLinkInvalid:     "商品购买链接无效: <%s>",
+		msgBuyFailed:           "商品(%s)购买失败: %+v",
+		msgAddCartFailed:       "商品(%s)加入购物车失败",
+		msgAddCartSuccess:      "购买结果：成功加入进购物车 [%d] 个 [%s]",
+		msgDeadlinePassed:      "商品(%s)到达截止时间仍无货",
+		msgPriceExceeded:       "商品(%s)价格 %s 超过上限 %.2f",
+		msgPaymentExceeded:     "应付款 %s 超过上限 %.2f, 不提交订单",
+		msgAddressFailed:       "获取收货地址失败: %+v",
+		msgAddressParseFailed:  "解析收货地址失败: %+v",
+		msgAddressNotFound:     "未找到收货地址: %+v",
+		msgAddressSelectFailed: "选择收货地址(%s)失败: %+v",
+		msgAddressSelected:     "收货地址: %s %s, 配送区域: %s",
+		msgAddressNoArea:       "无法确定收货地址(%s)的配送区域，仍使用: %s",
+		msgCartActionFailed:    "操作购物车商品(%s)失败: %+v",
+		msgLimitUnselectFailed: "商品(%s)超出限购, 但取消勾选失败, 请在购物车中手动取消: %v",
+		msgCountClamped:        "商品(%s)数量被限制: %d -> %d",
+		msgPurchaseLimit:       "商品(%s)超过限购数量: %d > %d",
+		msgOrderPolicy:         "不满足下单策略, 不提交订单: %+v",
+		msgFallback:            "商品(%s)无法购买, 改为购买(%s)",
+		msgGroupSkip:           "商品(%s)不能购买: %v, 尝试(%s)组中的其他商品",
+		msgPollRetry:           "商品(%s)库存查询失败(%d/%d), 继续重试: %+v",
+		msgSubmitCheck:         "提交订单出错，订单列表中还没有新订单，再次检查 (%d/%d): %v",
+		msgSubmitFound:         "提交订单出错，但订单列表中已有新订单 %s",
+		msgOrderListFailed:     "获取订单列表失败: %v",
+		msgProxyInvalid:        "代理设置错误，所有请求都将失败: %v",
+		msgSubmitGoodsUnknown:  "获取订单列表或购物车中选中的商品失败，提交出错时无法检查订单: %v",
+
+		MsgTableCart:     " \t编号\t数量\t价格\t总价\t商品",
+		MsgTableReport:   "编号\t请求数量\t数量\t详情\t查询次数\t等待库存\t加入购物车\t订单\t错误",
+		MsgTableRates:    "限流\t主机\t请求数\t等待数\t等待\t最长等待",
+		MsgTableAddress:  " \t编号\t姓名\t手机\t区域\t地址",
+		MsgTableStock:    "编号\t状态\t名称",
+		MsgTablePrice:    "编号\t价格",
+		MsgTableArea:     "编码\t名称",
+		MsgLabelProfile:  "账号",
+		MsgLabelSelected: "已选",
+		MsgLabelTotal:    "总额",
+		MsgLabelGoods:    "总金额",
+		MsgLabelShipping: "运费",
+		MsgLabelPayment:  "应付款",
+		MsgLabelMobile:   "手机",
+		MsgLabelAddress:  "地址",
+		MsgLabelPreview:  "预览",
+		MsgLabelSubmit:   "提交",
+		MsgLabelOrder:    "订单",
+		MsgLabelError:    "错误",
+		MsgLabelElapsed:  "耗时",
+		MsgLabelUser:     "用户",
+		MsgLabelNick:     "昵称",
+		MsgLabelLogin:    "登录时间",
+		MsgLabelExpires:  "过期时间(估计)",
+		MsgLabelID:       "编号",
+		MsgLabelName:     "名称",
+		MsgLabelPrice:    "价格",
+		MsgLabelStock:    "库存",
+		MsgLabelLink:     "链接",
+		MsgLabelVender:   "商家",
+	},
+	LangEnUS: {
+		msgRequestFailed:       "request (%+v) failed: %+v",
+		msgReadRespFailed:      "read response failed: %+v",
+		msgParseRespFailed:     "parse response failed: %+v",
+		msgHTTPStatus:          "http status : %d/%s",
+		msgLoadCookiesFailed:   "load cookies failed: %s",
+		msgSaveCookiesFailed:   "save cookies failed: %+v",
+		msgLoginPageFailed:     "load login page failed: %+v",
+		msgQRDownloadFailed:    "download QR code failed: %+v",
+		msgQRSaveFailed:        "save QR code failed: %+v",
+		msgQRInvalid:           "QR code invalid: %+v",
+		msgQRNoResult:          "QR code scan not detected",
+		msgQRValidateFailed:    "QR login validation failed: %+v",
+		msgRiskVerify:          "security verification: %s",
+		msgLoginFailedCode:     "login failed, return code: %d",
+		msgLoginFailed:         "login failed",
+		msgLoginSuccess:        "login succeeded, P3P: %s",
+		msgOpenVerifyFailed:    "open security verification page failed: %+v",
+		msgWaitVerify:          "please finish the security verification in %v",
+		msgWaitVerifyTimeout:   "timeout waiting for security verification",
+		msgAlreadyLogin:        "already logged in, user: %s",
+		msgScanQR:              "please open the JD mobile app and scan the QR code to login:",
+		msgOpenQRFailed:        "open QR code image failed: %+v.",
+		msgNeedLogin:           "login required",
+		msgNeedLoginErr:        "login required: %+v",
+		msgNeedLoginRedirect:   "login required, redirected to: %s",
+		msgNeedLoginStatus:     "login required, %s",
+		msgNeedLoginNoUser:     "login required, user info not found",
+		msgKeeperStarted:       "session keeper started, interval: %v",
+		msgSessionExpiring:     "session expires soon: %s",
+		msgSessionExpired:      "session expired, login again",
+		msgReloginFailed:       "login again failed: %+v",
+		msgRefreshFailed:       "refresh session failed: %+v",
+		msgSessionRefreshed:    "session refreshed",
+		msgNotifyFailed:        "send notification failed: %+v",
+		msgNotifyExpiring:      "JD session expires soon, please scan the QR code to login again",
+		msgNotifyExpired:       "JD session expired, please scan the QR code to login again",
+		msgCartTitle:           "Cart details>",
+		msgCartFailed:          "get cart details failed: %+v",
+		msgCartParseFailed:     "parse cart page failed: %+v.",
+		msgCartHeader:          "Check Qty   Price     Total     ID          Goods",
+		msgCartTotalCount:      "Count: %s",
+		msgCartTotalValue:      "Total: %s",
+		msgOrderTitle:          "Order details>",
+		msgOrderFailed:         "get order page failed: %+v",
+		msgOrderParseFailed:    "parse order page failed: %+v.",
+		msgOrderWarePrice:      "Goods amount: %s",
+		msgOrderShipPrice:      "Shipping fee: %s",
+		msgOrderPayment:        "Payable: %s",
+		msgSubmitTitle:         "Submit order>",
+		msgSubmitNotLogin:      "session expired, can not submit order: %+v",
+		msgSubmitFailed:        "submit order failed: %+v",
+		msgSubmitParseFailed:   "parse order response failed: %+v",
+		msgSubmitSuccess:       "order submitted, order ID: %d",
+		msgSubmitRejected:      "order rejected, %s : %s",
+		msgPriceFailed:         "get price of (%s) failed: %+v",
+		msgStockFailed:         "get stock of (%s) failed: %+v",
+		msgStockParseFailed:    "parse stock data failed: %+v",
+		msgSKUPageFailed:       "get goods page failed: %+v",
+		msgSKUPageParseFailed:  "parse goods page failed: %+v",
+		msgSKUTitle:            "Goods details>",
+		msgSKUDetail:           "ID: %s, stock: %s, price: %s",
+		msgChangeCountFailed:   "change goods count failed: %+v",
+		msgCartLink:            "cart link: %s",
+		msgCartLinkInvalid:     "invalid cart link: <%s>",
+		msgBuyFailed:           "buy goods (%s) failed: %+v",
+		msgAddCartFailed:       "add goods (%s) to cart failed",
+		msgAddCartSuccess:      "added to cart: [%d] x [%s]",
+		msgDeadlinePassed:      "goods (%s) still out of stock at the deadline",
+		msgPriceExceeded:       "price of goods (%s) %s exceeds the limit %.2f",
+		msgPaymentExceeded:     "payment %s exceeds the limit %.2f, order not submitted",
+		msgAddressFailed:       "get consignee addresses failed: %+v",
+		msgAddressParseFailed:  "parse consignee addresses failed: %+v",
+		msgAddressNotFound:     "consignee address not found: %+v",
+		msgAddressSelectFailed: "select consignee address (%s) failed: %+v",
+		msgAddressSelected:     "ship to: %s %s, area: %s",
+		msgAddressNoArea:       "the ship area of address (%s) is unknown, keep the area: %s",
+		msgCartActionFailed:    "cart operation on (%s) failed: %+v",
+		msgLimitUnselectFailed: "goods (%s) exceeds the purchase limit, but unselecting it failed, unselect it in cart by hand: %v",
+		msgCountClamped:        "count of goods (%s) clamped: %d -> %d",
+		msgPurchaseLimit:       "goods (%s) exceeds the purchase limit: %d > %d",
+		msgOrderPolicy:         "order is not submitted: %+v",
+		msgFallback:            "goods (%s) can not be bought, buy (%s) instead",
+		msgGroupSkip:           "goods (%s) can not be bought: %v, try the others of group (%s)",
+		msgPollRetry:           "poll stock of (%s) failed (%d/%d), retrying: %+v",
+		msgSubmitCheck:         "submit order failed and no new order in the order list yet, check again (%d/%d): %v",
+		msgSubmitFound:         "submit order failed, but the new order %s is found in the order list",
+		msgOrderListFailed:     "get the order list failed: %v",
+		msgProxyInvalid:        "invalid proxy, all the requests will fail: %v",
+		msgSubmitGoodsUnknown:  "get the order list or the selected goods in cart failed, the order can not be checked if submit fails: %v",
+
+		MsgTableCart:     " \tID\tCount\tPrice\tTotal\tName",
+		MsgTableReport:   "ID\tRequested\tCount\tDetail\tPolls\tStock Wait\tAdd to Cart\tOrder\tError",
+		MsgTableRates:    "Rate Limit\tHost\tRequests\tWaited\tWait\tMax Wait",
+		MsgTableAddress:  " \tID\tName\tMobile\tArea\tAddress",
+		MsgTableStock:    "ID\tState\tName",
+		MsgTablePrice:    "ID\tPrice",
+		MsgTableArea:     "Code\tName",
+		MsgLabelProfile:  "Profile",
+		MsgLabelSelected: "Selected",
+		MsgLabelTotal:    "Total",
+		MsgLabelGoods:    "Goods",
+		MsgLabelShipping: "Shipping",
+		MsgLabelPayment:  "Payment",
+		MsgLabelMobile:   "Mobile",
+		MsgLabelAddress:  "Address",
+		MsgLabelPreview:  "Preview",
+		MsgLabelSubmit:   "Submit",
+		MsgLabelOrder:    "Order",
+		MsgLabelError:    "Error",
+		MsgLabelElapsed:  "Elapsed",
+		MsgLabelUser:     "User",
+		MsgLabelNick:     "Nick",
+		MsgLabelLogin:    "Login",
+		MsgLabelExpires:  "Expires (estimated)",
+		MsgLabelID:       "ID",
+		MsgLabelName:     "Name",
+		MsgLabelPrice:    "Price",
+		MsgLabelStock:    "Stock",
+		MsgLabelLink:     "Link",
+		MsgLabelVender:   "Vender",
+	},
+}
+
+// RegisterMessages add or override the message formats of lang, it should
+// be called before any JingDong object is created.
+//
+func RegisterMessages(lang Lang, msgs map[MsgID]string) {
+	if catalog[lang] == nil {
+		catalog[lang] = make(map[MsgID]string, len(msgs))
+	}
+	for id, msg := range msgs {
+		catalog[lang][id] = msg
+	}
+}
+
+// Message return the message format of id in lang, fallback to DefaultLang
+// if it is not translated.
+//
+func Message(lang Lang, id MsgID) string {
+	if msg, ok := catalog[lang][id]; ok {
+		return msg
+	}
+	if msg, ok := catalog[DefaultLang][id]; ok {
+		return msg
+	}
+	return string(id)
+}
+
+// msg return the message format of id in the configured language
+//
+func (jd *JingDong) msg(id MsgID) string {
+	return Message(jd.Lang, id)
+}
@@ -0,0 +1,267 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Middleware wrap the RoundTripper of JingDong to apply cross-cutting
+// behavior to all the requests, e.g. headers, retries and logging
+//
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to use a func as http.RoundTripper
+//
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip call f(req)
+//
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wrap base with the middlewares, the first one is the outermost
+// and sees the request first
+//
+func Chain(base http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	for i := len(mws) - 1; i >= 0; i-- {
+		base = mws[i](base)
+	}
+	return base
+}
+
+// middlewares return the chain of JingDong, the user middlewares of
+// JDConfig.Middlewares are between the logging and the retries:
+//
+//   headers -> logging -> JDConfig.Middlewares -> retry -> rate limit
+//
+func (jd *JingDong) middlewares() []Middleware {
+	mws := []Middleware{
+		HeaderMiddleware(DefaultHeaders),
+		LogMiddleware(jd.Logger),
+	}
+	mws = append(mws, jd.Middlewares...)
+	return append(mws,
+		RetryMiddleware(jd.MaxRetries, time.Millisecond*200),
+		RateLimitMiddleware(),
+	)
+}
+
+// HeaderMiddleware set the headers not set by the request, so that the
+// headers of a request like Referer take precedence
+//
+func HeaderMiddleware(header map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for key, val := range header {
+				if req.Header.Get(key) == "" {
+					req.Header.Set(key, val)
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// LogMiddleware trace each request with the status and elapsed time
+//
+func LogMiddleware(logger Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				logger.Trace("%s %s: %v (%s)", req.Method, req.URL, err, time.Since(start))
+				return nil, err
+			}
+			logger.Trace("%s %s: %s (%s)", req.Method, req.URL, resp.Status, time.Since(start))
+			return resp, nil
+		})
+	}
+}
+
+// unsafePaths are the requests not to retry even by GET, adding to cart
+// twice doubles the count, and the order is never submitted twice
+//
+var unsafePaths = map[string]bool{
+	"/gate.action":                       true,
+	"/shopping/order/submitOrder.action": true,
+}
+
+// safePaths are the POST requests safe to retry, they set the state of
+// cart or order to the same result however many times done
+//
+var safePaths = map[string]bool{
+	"/changeNum.action":                                true,
+	"/selectItem.action":                               true,
+	"/cancelItem.action":                               true,
+	"/selectAllItem.action":                            true,
+	"/cancelAllItem.action":                            true,
+	"/batchRemoveSkusFromCart.action":                  true,
+	"/shopping/dynamic/consignee/saveConsignee.action": true,
+}
+
+// idempotent report whether req can be sent again safely
+//
+func idempotent(req *http.Request) bool {
+	if unsafePaths[req.URL.Path] {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		return safePaths[req.URL.Path]
+	}
+	return false
+}
+
+// RetryMiddleware retry the idempotent requests up to max times on network
+// errors and 502, 503 or 504, waiting backoff doubled each time. Adding to
+// cart, submitting order and unknown POST requests are never retried, they
+// may have been done by JD.
+//
+func RetryMiddleware(max int, backoff time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if max <= 0 || !idempotent(req) {
+				return next.RoundTrip(req)
+			}
+
+			wait := backoff
+			for i := 0; ; i++ {
+				if i > 0 && req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+
+				resp, err := next.RoundTrip(req)
+				if i >= max || !retryable(resp, err) {
+					return resp, err
+				}
+				if resp != nil {
+					io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+				}
+
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-req.Context().Done():
+					t.Stop()
+					return nil, req.Context().Err()
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+// retryable report whether the request failed by the network or a
+// temporary failure of the gateway
+//
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var nerr net.Error
+		return errors.As(err, &nerr) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// 503 with Retry-After is throttling, left to the caller
+		return resp.Header.Get("Retry-After") == ""
+	}
+	return false
+}
+
+// RateLimitMiddleware limit the requests by the endpoint group of each
+// host, see SetRateLimits
+//
+func RateLimitMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &rateTransport{base: next}
+	}
+}
+
+// RequestStat is the metrics of a request
+//
+type RequestStat struct {
+	Time    time.Time     `json:"time"`
+	Method  string        `json:"method"`
+	URL     string        `json:"url"`
+	Group   EndpointGroup `json:"group"`
+	Status  int           `json:"status,omitempty"`
+	Err     error         `json:"-"`
+	Elapsed time.Duration `json:"-"`
+}
+
+// MarshalJSON encode Err as string and Elapsed as milliseconds
+//
+func (s RequestStat) MarshalJSON() ([]byte, error) {
+	type stat RequestStat
+	v := struct {
+		stat
+		Error     string  `json:"error,omitempty"`
+		ElapsedMS float64 `json:"elapsed_ms"`
+	}{
+		stat:      stat(s),
+		ElapsedMS: millis(s.Elapsed),
+	}
+	if s.Err != nil {
+		v.Error = s.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// MetricsMiddleware call observe with the metrics of each request, it may
+// be called from multiple goroutines concurrently
+//
+func MetricsMiddleware(observe func(RequestStat)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			st := RequestStat{
+				Time:    start,
+				Method:  req.Method,
+				URL:     req.URL.String(),
+				Group:   endpointGroup(req),
+				Err:     err,
+				Elapsed: time.Since(start),
+			}
+			if resp != nil {
+				st.Status = resp.StatusCode
+			}
+			observe(st)
+			return resp, err
+		})
+	}
+}
+
+// RecordMiddleware write the metrics of each request to w as a JSON line,
+// e.g. to find out the requests before JD throttles the account
+//
+func RecordMiddleware(w io.Writer) Middleware {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return MetricsMiddleware(func(st RequestStat) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(st)
+	})
+}
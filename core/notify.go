@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Notifier presents login prompts and status messages to the operator.
+//
+// The default implementation opens the QR code image or verification page on
+// the local desktop. Custom implementations can forward them to a remote
+// operator (IM bot, mail, webhook ...) when running on a headless server.
+//
+type Notifier interface {
+	// QRCode is called with the path of the downloaded login QR code image
+	QRCode(filename string) error
+
+	// RiskVerify is called with the JD security verification page which must
+	// be opened and finished by the account owner before login completes
+	RiskVerify(URL string) error
+
+	// Notify send a plain status message, e.g. the session is expired
+	Notify(msg string) error
+}
+
+// DesktopNotifier open QR code and verification page with the system viewer
+//
+type DesktopNotifier struct{}
+
+// QRCode open the QR code image
+//
+func (DesktopNotifier) QRCode(filename string) error {
+	return runCommand(filename)
+}
+
+// RiskVerify open the verification page in browser
+//
+func (DesktopNotifier) RiskVerify(URL string) error {
+	return runCommand(URL)
+}
+
+// Notify print the message to stderr
+//
+func (DesktopNotifier) Notify(msg string) error {
+	_, err := fmt.Fprintf(os.Stderr, "%s [JD] %s\n", time.Now().Format("2006/01/02 15:04:05"), msg)
+	return err
+}
+
+// WebhookNotifier post the notifications as JSON to URL, so that a bot can
+// forward them to the operator, the payload looks like:
+//
+//    {"event": "qrcode", "message": "...", "url": "...", "image": "<base64>"}
+//
+// event is one of "qrcode", "risk_verify" and "message".
+//
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // default to a client with 10s timeout
+}
+
+type webhookPayload struct {
+	Event   string `json:"event"`
+	Message string `json:"message,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Image   string `json:"image,omitempty"` // base64 encoded QR code image
+}
+
+// QRCode post the QR code image
+//
+func (n *WebhookNotifier) QRCode(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return n.post(webhookPayload{
+		Event:   "qrcode",
+		Message: "scan the QR code with JD app to login",
+		Image:   base64.StdEncoding.EncodeToString(data),
+	})
+}
+
+// RiskVerify post the verification page
+//
+func (n *WebhookNotifier) RiskVerify(URL string) error {
+	return n.post(webhookPayload{
+		Event:   "risk_verify",
+		Message: "open the page to finish JD security verification",
+		URL:     URL,
+	})
+}
+
+// Notify post the message
+//
+func (n *WebhookNotifier) Notify(msg string) error {
+	return n.post(webhookPayload{
+		Event:   "message",
+		Message: msg,
+	})
+}
+
+func (n *WebhookNotifier) post(payload webhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 10}
+	}
+
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", n.URL, resp.Status)
+	}
+	return nil
+}
+
+// runCommand open file or URL with platform default program
+//
+func runCommand(strCmd string) error {
+	var err error
+	var cmd *exec.Cmd
+
+	// for different platform
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", strCmd)
+	case "linux":
+		cmd = exec.Command("eog", strCmd)
+	default:
+		cmd = exec.Command("open", strCmd)
+	}
+
+	// just start, do not wait it complete
+	if err = cmd.Start(); err != nil {
+		if runtime.GOOS == "linux" {
+			cmd = exec.Command("gnome-open", strCmd)
+			return cmd.Start()
+		}
+		return err
+	}
+	return nil
+}
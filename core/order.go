@@ -0,0 +1,209 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// URLOrderList is the order list of the account, the latest first
+//
+const URLOrderList = "https://order.jd.com/center/list.action"
+
+// JD shows the order time in Beijing time
+var locBeijing = time.FixedZone("CST", 8*3600)
+
+// errOrderNotFound means no new order with the goods is in the order list
+var errOrderNotFound = errors.New("no new order with the goods in the order list")
+
+// Order is an order in the order list
+//
+type Order struct {
+	ID    string       `json:"id"`
+	Time  time.Time    `json:"time"`
+	Items []*OrderItem `json:"items"`
+}
+
+// OrderItem is the goods and count in an order
+//
+type OrderItem struct {
+	ID    string `json:"id"`
+	Count int    `json:"count"`
+}
+
+var (
+	// goods-item p-5089253
+	reOrderSKU = regexp.MustCompile(`\bp-(\d+)\b`)
+
+	// x2
+	reOrderCount = regexp.MustCompile(`(\d+)`)
+)
+
+// RecentOrders list the orders on the first page of order list
+//
+//  <tbody id="tb-60123456789">
+//    <tr class="tr-th">
+//      <td><span class="dealtime" title="2017-07-11 14:32:10">...</span></td>
+//      ...
+//    <tr class="tr-bd">
+//      <td>
+//        <div class="goods-item p-5089253">...</div>
+//        <div class="goods-number">x1</div>
+//      ...
+//
+func (jd *JingDong) RecentOrders() ([]*Order, error) {
+	data, err := jd.getResponse("GET", URLOrderList, nil)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgOrderListFailed), err)
+		return nil, err
+	}
+
+	orders, err := parseOrders(data)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgOrderListFailed), err)
+	}
+	return orders, err
+}
+
+// parseOrders parse the order list page
+//
+func parseOrders(data []byte) ([]*Order, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, newParseError("order list", data, err)
+	}
+
+	var orders []*Order
+	doc.Find("tbody[id^='tb-']").Each(func(i int, s *goquery.Selection) {
+		order := &Order{ID: strings.TrimPrefix(s.AttrOr("id", ""), "tb-")}
+		deal := s.Find("span.dealtime").Eq(0)
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05",
+			strings.TrimSpace(deal.AttrOr("title", deal.Text())), locBeijing); err == nil {
+			order.Time = t
+		}
+
+		s.Find("div.goods-item").Each(func(i int, g *goquery.Selection) {
+			m := reOrderSKU.FindStringSubmatch(g.AttrOr("class", ""))
+			if m == nil {
+				return
+			}
+			item := &OrderItem{ID: m[1], Count: 1}
+			num := g.Parent().Find("div.goods-number").Eq(0)
+			if c := reOrderCount.FindString(num.Text()); c != "" {
+				item.Count, _ = strconv.Atoi(c)
+			}
+			order.Items = append(order.Items, item)
+		})
+		orders = append(orders, order)
+	})
+
+	if len(orders) == 0 && doc.Find("div.order-empty, .empty-box").Length() == 0 {
+		return nil, newParseError("order list", data, nil)
+	}
+	return orders, nil
+}
+
+// goods return the count of each goods in the order
+//
+func (o *Order) goods() map[string]int {
+	goods := make(map[string]int, len(o.Items))
+	for _, item := range o.Items {
+		goods[item.ID] += item.Count
+	}
+	return goods
+}
+
+// orderIDs return the IDs of the recent orders, to tell the orders created
+// after from the order list
+//
+func (jd *JingDong) orderIDs() (map[string]bool, error) {
+	orders, err := jd.RecentOrders()
+	if err != nil {
+		return nil, err
+	}
+	IDs := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		IDs[order.ID] = true
+	}
+	return IDs, nil
+}
+
+// findOrder return the ID of the order not in known with exactly the goods
+// and counts, empty if none
+//
+func (jd *JingDong) findOrder(known map[string]bool, goods map[string]int) (string, error) {
+	orders, err := jd.RecentOrders()
+	if err != nil {
+		return "", err
+	}
+	return matchOrder(orders, known, goods), nil
+}
+
+// matchOrder return the ID of the first order not in known with the goods,
+// empty if none. No order matches empty goods.
+//
+func matchOrder(orders []*Order, known map[string]bool, goods map[string]int) string {
+	if len(goods) == 0 {
+		return ""
+	}
+	for _, order := range orders {
+		if !known[order.ID] && sameGoods(order.goods(), goods) {
+			return order.ID
+		}
+	}
+	return ""
+}
+
+// sameGoods report whether a and b have the same goods and counts
+//
+func sameGoods(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ID, n := range a {
+		if b[ID] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedGoods return the count of each selected goods in cart, which is
+// what the order is created with
+//
+func (jd *JingDong) selectedGoods() (map[string]int, error) {
+	cart, err := jd.CartDetails()
+	if err != nil {
+		return nil, err
+	}
+	goods := make(map[string]int)
+	for _, item := range cart.Items {
+		if item.Checked {
+			goods[item.ID] += item.Count
+		}
+	}
+	return goods, nil
+}
+
+// submitUncertain report whether the order may have been created by JD
+// despite of err, e.g. the connection is lost after the request is sent
+//
+func submitUncertain(err error) bool {
+	return err != nil &&
+		!errors.Is(err, ErrOrderRejected) &&
+		!errors.Is(err, ErrNotLoggedIn) &&
+		!errors.Is(err, ErrRiskVerification)
+}
+
+// orderStateUnknown return ErrOrderStateUnknown with the error of submit
+// and the error of checking the order list
+//
+func orderStateUnknown(submitErr, checkErr error) error {
+	return fmt.Errorf("%w: submit: %v, check order list: %v", ErrOrderStateUnknown, submitErr, checkErr)
+}
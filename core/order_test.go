@@ -0,0 +1,85 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const orderList = `<table>
+<tbody id="tb-2002">
+  <tr class="tr-th"><td><span class="dealtime" title="2017-07-11 14:32:10">2017-07-11 14:32:10</span></td></tr>
+  <tr class="tr-bd"><td>
+    <div class="goods-item p-531065"><a href="//item.jd.com/531065.html">A</a></div>
+    <div class="goods-number">x2</div>
+  </td></tr>
+  <tr class="tr-bd"><td>
+    <div class="goods-item p-3133811"><a href="//item.jd.com/3133811.html">B</a></div>
+    <div class="goods-number">x1</div>
+  </td></tr>
+</tbody>
+<tbody id="tb-2001">
+  <tr class="tr-th"><td><span class="dealtime">2017-07-11 14:32:05</span></td></tr>
+  <tr class="tr-bd"><td>
+    <div class="goods-item p-531065"></div>
+  </td></tr>
+</tbody>
+</table>`
+
+func TestParseOrders(t *testing.T) {
+	orders, err := parseOrders([]byte(orderList))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Order{
+		{
+			ID:    "2002",
+			Time:  time.Date(2017, 7, 11, 14, 32, 10, 0, locBeijing),
+			Items: []*OrderItem{{ID: "531065", Count: 2}, {ID: "3133811", Count: 1}},
+		},
+		{
+			ID:    "2001",
+			Time:  time.Date(2017, 7, 11, 14, 32, 5, 0, locBeijing),
+			Items: []*OrderItem{{ID: "531065", Count: 1}},
+		},
+	}
+	if !reflect.DeepEqual(orders, want) {
+		t.Errorf("parseOrders = %+v, want %+v", orders, want)
+	}
+
+	if _, err := parseOrders([]byte("<html></html>")); err == nil {
+		t.Error("parseOrders of unknown page succeeded, want error")
+	}
+	if orders, err := parseOrders([]byte(`<div class="order-empty"></div>`)); err != nil || len(orders) != 0 {
+		t.Errorf("parseOrders of empty list = %+v, %v, want none", orders, err)
+	}
+}
+
+func TestMatchOrder(t *testing.T) {
+	orders, err := parseOrders([]byte(orderList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := map[string]bool{"2001": true}
+
+	tests := []struct {
+		name  string
+		known map[string]bool
+		goods map[string]int
+		want  string
+	}{
+		{"same goods", before, map[string]int{"531065": 2, "3133811": 1}, "2002"},
+		{"known order", before, map[string]int{"531065": 1}, ""},
+		{"no known order", nil, map[string]int{"531065": 1}, "2001"},
+		{"other count", before, map[string]int{"531065": 1, "3133811": 1}, ""},
+		{"fewer goods", before, map[string]int{"531065": 2}, ""},
+		{"more goods", before, map[string]int{"531065": 2, "3133811": 1, "1": 1}, ""},
+		{"no goods", nil, nil, ""},
+	}
+	for _, tt := range tests {
+		if got := matchOrder(orders, tt.known, tt.goods); got != tt.want {
+			t.Errorf("%s: matchOrder = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
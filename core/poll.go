@@ -0,0 +1,194 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// PollStrategy decide the interval before the next stock poll. poll is
+// the count of polls done, errs is the count of consecutive errors and
+// err is the error of last poll. It is shared by the goods rushed at the
+// same time, so it should not keep the state of polls.
+//
+type PollStrategy interface {
+	Next(poll, errs int, err error) time.Duration
+}
+
+// FixedPoll polls at a fixed period, it is the default with JDConfig.Period
+//
+type FixedPoll struct {
+	Period time.Duration
+}
+
+// Next return the period
+//
+func (p FixedPoll) Next(poll, errs int, err error) time.Duration {
+	return p.Period
+}
+
+// JitterPoll polls at the period with a random jitter, e.g. Jitter 0.2
+// polls in [0.8, 1.2] * Period, so the polls look less like a robot
+//
+type JitterPoll struct {
+	Period time.Duration
+	Jitter float64
+}
+
+// Next return the period with jitter
+//
+func (p JitterPoll) Next(poll, errs int, err error) time.Duration {
+	return jitter(p.Period, p.Jitter)
+}
+
+func jitter(d time.Duration, j float64) time.Duration {
+	if j <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + j*(2*rand.Float64()-1)))
+}
+
+// BackoffPoll polls at the period, and backs off exponentially by Factor
+// on consecutive errors, up to Max. When JD throttles by HTTP 429/503, it
+// waits at least the Retry-After.
+//
+type BackoffPoll struct {
+	Period time.Duration
+	Max    time.Duration // default to 30s
+	Factor float64       // default to 2
+	Jitter float64       // optional jitter of each interval
+}
+
+// Next return the period, or the backoff interval after errors
+//
+func (p BackoffPoll) Next(poll, errs int, err error) time.Duration {
+	d := p.Period
+	if errs > 0 {
+		factor, max := p.Factor, p.Max
+		if factor <= 1 {
+			factor = 2
+		}
+		if max <= 0 {
+			max = 30 * time.Second
+		}
+
+		// back off twice as fast when throttled
+		n := float64(errs)
+		if errors.Is(err, ErrThrottled) {
+			n++
+		}
+		if d = time.Duration(float64(p.Period) * math.Pow(factor, n)); d > max || d <= 0 {
+			d = max
+		}
+	}
+
+	var serr *HTTPStatusError
+	if errors.As(err, &serr) && serr.RetryAfter > d {
+		d = serr.RetryAfter
+	}
+	return jitter(d, p.Jitter)
+}
+
+// BurstPoll polls at the Period, and at the Burst period within Window
+// around the scheduled Start time of a sale, e.g. 10:00:00 ± 5s
+//
+type BurstPoll struct {
+	Start  time.Time
+	Window time.Duration
+	Period time.Duration
+	Burst  time.Duration
+}
+
+// Next return the burst period in the window, otherwise the period but
+// not later than the window begins
+//
+func (p BurstPoll) Next(poll, errs int, err error) time.Duration {
+	now := time.Now()
+	begin, end := p.Start.Add(-p.Window), p.Start.Add(p.Window)
+
+	switch {
+	case now.Before(begin):
+		if wait := begin.Sub(now); wait < p.Period {
+			return wait
+		}
+		return p.Period
+	case now.Before(end):
+		return p.Burst
+	}
+	return p.Period
+}
+
+// ParsePollStrategy return the strategy by name, fixed, jitter, backoff
+// or burst. start and window are used by burst only.
+//
+func ParsePollStrategy(name string, period time.Duration, start time.Time, window time.Duration) (PollStrategy, error) {
+	switch name {
+	case "", "fixed":
+		return FixedPoll{Period: period}, nil
+	case "jitter":
+		return JitterPoll{Period: period, Jitter: 0.2}, nil
+	case "backoff":
+		return BackoffPoll{Period: period, Jitter: 0.1}, nil
+	case "burst":
+		if start.IsZero() {
+			return nil, errors.New("burst poll needs the start time")
+		}
+		burst := period / 5
+		if burst < 50*time.Millisecond {
+			burst = 50 * time.Millisecond
+		}
+		return BurstPoll{Start: start, Window: window, Period: period, Burst: burst}, nil
+	}
+	return nil, fmt.Errorf("unknown poll strategy %q, want fixed, jitter, backoff or burst", name)
+}
+
+// poller track the polls of a goods, shared by waitStock and waitGroup
+//
+type poller struct {
+	jd       *JingDong
+	ID       string
+	deadline time.Time // zero means no deadline
+	poll     int       // count of polls
+	errs     int       // count of consecutive errors
+	err      error     // error of last poll
+}
+
+// wait sleep before the next poll, return ErrDeadline if the deadline
+// is passed before the poll
+//
+func (p *poller) wait() error {
+	jd := p.jd
+	d := jd.Poll.Next(p.poll, p.errs, p.err)
+	if !p.deadline.IsZero() && time.Now().Add(d).After(p.deadline) {
+		jd.Logger.Warn(jd.msg(msgDeadlinePassed), p.ID)
+		return &ItemError{ID: p.ID, Err: ErrDeadline}
+	}
+
+	time.Sleep(d)
+	p.poll++
+	return nil
+}
+
+// done record the result of poll, return err if there are too many
+// consecutive errors
+//
+func (p *poller) done(err error) error {
+	p.err = err
+	if err == nil {
+		p.errs = 0
+		return nil
+	}
+
+	p.errs++
+	max := p.jd.MaxPollErrors
+	if max <= 0 {
+		max = 1
+	}
+	if p.errs >= max {
+		return err
+	}
+	p.jd.Logger.Warn(p.jd.msg(msgPollRetry), p.ID, p.errs, max, err)
+	return nil
+}
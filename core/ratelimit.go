@@ -0,0 +1,341 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndpointGroup classify the JD endpoints, each group of a host has its
+// own rate limit
+//
+type EndpointGroup string
+
+const (
+	GroupStock EndpointGroup = "stock" // c0.3.cn
+	GroupPrice EndpointGroup = "price" // p.3.cn
+	GroupCart  EndpointGroup = "cart"  // cart.jd.com
+	GroupOrder EndpointGroup = "order" // trade.jd.com
+	GroupOther EndpointGroup = "other" // login, goods page and the others
+)
+
+// RateLimit is the token bucket and in-flight cap of an endpoint group
+//
+type RateLimit struct {
+	Rate        float64 // requests per second, 0 means no limit
+	Burst       int     // requests allowed at once, default to 1
+	MaxInFlight int     // requests in flight at the same time, 0 means no limit
+}
+
+// DefaultRateLimits is conservative enough for a few goods rushed with
+// several profiles, the limits are shared by all JingDong of the process
+//
+var DefaultRateLimits = map[EndpointGroup]RateLimit{
+	GroupStock: {Rate: 10, Burst: 5, MaxInFlight: 4},
+	GroupPrice: {Rate: 5, Burst: 5, MaxInFlight: 2},
+	GroupCart:  {Rate: 10, Burst: 5, MaxInFlight: 4},
+	GroupOrder: {Rate: 2, Burst: 2, MaxInFlight: 2},
+}
+
+var hostGroups = map[string]EndpointGroup{
+	"c0.3.cn":      GroupStock,
+	"p.3.cn":       GroupPrice,
+	"cart.jd.com":  GroupCart,
+	"trade.jd.com": GroupOrder,
+}
+
+// endpointGroup return the group of request by host
+//
+func endpointGroup(req *http.Request) EndpointGroup {
+	if g, ok := hostGroups[req.URL.Hostname()]; ok {
+		return g
+	}
+	return GroupOther
+}
+
+// ParseRateLimits parse the limits like `stock=10/5/4,order=2`, each is
+// group=rate[/burst[/max-in-flight]]
+//
+func ParseRateLimits(s string) (map[EndpointGroup]RateLimit, error) {
+	limits := make(map[EndpointGroup]RateLimit)
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		g := EndpointGroup(strings.TrimSpace(kv[0]))
+		switch g {
+		case GroupStock, GroupPrice, GroupCart, GroupOrder, GroupOther:
+		default:
+			return nil, fmt.Errorf("unknown endpoint group %q, want stock, price, cart, order or other", kv[0])
+		}
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rate limit %q, want group=rate[/burst[/max-in-flight]]", part)
+		}
+
+		var (
+			l   RateLimit
+			err error
+		)
+		fields := strings.Split(kv[1], "/")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("invalid rate limit %q, want group=rate[/burst[/max-in-flight]]", part)
+		}
+		if l.Rate, err = strconv.ParseFloat(fields[0], 64); err != nil || l.Rate < 0 {
+			return nil, fmt.Errorf("invalid rate of %s: %q", g, fields[0])
+		}
+		if len(fields) > 1 {
+			if l.Burst, err = strconv.Atoi(fields[1]); err != nil || l.Burst < 0 {
+				return nil, fmt.Errorf("invalid burst of %s: %q", g, fields[1])
+			}
+		}
+		if len(fields) > 2 {
+			if l.MaxInFlight, err = strconv.Atoi(fields[2]); err != nil || l.MaxInFlight < 0 {
+				return nil, fmt.Errorf("invalid max in flight of %s: %q", g, fields[2])
+			}
+		}
+		limits[g] = l
+	}
+	return limits, nil
+}
+
+// RateStat is the metrics of an endpoint group of a host
+//
+type RateStat struct {
+	Group    EndpointGroup `json:"group"`
+	Host     string        `json:"host"`
+	Requests int           `json:"requests"`
+	Waited   int           `json:"waited"` // requests delayed by the limit
+	Wait     time.Duration `json:"-"`      // total wait time
+	MaxWait  time.Duration `json:"-"`
+	InFlight int           `json:"in_flight"`
+}
+
+// MarshalJSON encode the wait time as milliseconds
+//
+func (s RateStat) MarshalJSON() ([]byte, error) {
+	type stat RateStat
+	return json.Marshal(struct {
+		stat
+		WaitMS    float64 `json:"wait_ms"`
+		MaxWaitMS float64 `json:"max_wait_ms"`
+	}{
+		stat:      stat(s),
+		WaitMS:    millis(s.Wait),
+		MaxWaitMS: millis(s.MaxWait),
+	})
+}
+
+// limiter is the token bucket and the semaphore of in-flight requests
+//
+type limiter struct {
+	mu     sync.Mutex
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+	sem    chan struct{}
+	stat   RateStat
+}
+
+func newLimiter(g EndpointGroup, host string, l RateLimit) *limiter {
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	lim := &limiter{
+		limit:  l,
+		tokens: float64(l.Burst),
+		last:   time.Now(),
+		stat:   RateStat{Group: g, Host: host},
+	}
+	if l.MaxInFlight > 0 {
+		lim.sem = make(chan struct{}, l.MaxInFlight)
+	}
+	return lim
+}
+
+// reserve take a token, return how long to wait for it
+//
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit.Rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.limit.Rate
+	if max := float64(l.limit.Burst); l.tokens > max {
+		l.tokens = max
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.limit.Rate * float64(time.Second))
+}
+
+// acquire wait for a token and a slot of in-flight requests, the slot
+// must be released by the returned func
+//
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if d := l.reserve(); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			if l.sem != nil {
+				<-l.sem
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	wait := time.Since(start)
+	l.mu.Lock()
+	l.stat.Requests++
+	l.stat.InFlight++
+	if wait >= time.Millisecond {
+		l.stat.Waited++
+		l.stat.Wait += wait
+		if wait > l.stat.MaxWait {
+			l.stat.MaxWait = wait
+		}
+	}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.stat.InFlight--
+			l.mu.Unlock()
+			if l.sem != nil {
+				<-l.sem
+			}
+		})
+	}, nil
+}
+
+var (
+	rateMu   sync.Mutex
+	rateCfg  = DefaultRateLimits
+	limiters = make(map[string]*limiter)
+)
+
+// SetRateLimits replace the limits of the groups given, the others keep
+// the current limits. It applies to the requests after it returns.
+//
+func SetRateLimits(limits map[EndpointGroup]RateLimit) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+
+	cfg := make(map[EndpointGroup]RateLimit, len(rateCfg)+len(limits))
+	for g, l := range rateCfg {
+		cfg[g] = l
+	}
+	for g, l := range limits {
+		cfg[g] = l
+		for key, lim := range limiters {
+			if lim.stat.Group == g {
+				delete(limiters, key)
+			}
+		}
+	}
+	rateCfg = cfg
+}
+
+// RateStats return the metrics of the endpoints requested, ordered by
+// group and host
+//
+func RateStats() []RateStat {
+	rateMu.Lock()
+	stats := make([]RateStat, 0, len(limiters))
+	for _, l := range limiters {
+		l.mu.Lock()
+		stats = append(stats, l.stat)
+		l.mu.Unlock()
+	}
+	rateMu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Group != stats[j].Group {
+			return stats[i].Group < stats[j].Group
+		}
+		return stats[i].Host < stats[j].Host
+	})
+	return stats
+}
+
+// limiterFor return the limiter of the endpoint group and host of req,
+// shared by all JingDong of the process
+//
+func limiterFor(req *http.Request) *limiter {
+	g, host := endpointGroup(req), req.URL.Hostname()
+	key := string(g) + "|" + host
+
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	l, ok := limiters[key]
+	if !ok {
+		l = newLimiter(g, host, rateCfg[g])
+		limiters[key] = l
+	}
+	return l
+}
+
+// rateTransport limit the requests by the endpoint group before sending,
+// the in-flight slot is released when the response body is closed
+//
+type rateTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := limiterFor(req).acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
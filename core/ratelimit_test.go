@@ -0,0 +1,58 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		s    string
+		want map[EndpointGroup]RateLimit
+	}{
+		{"", map[EndpointGroup]RateLimit{}},
+		{" , ", map[EndpointGroup]RateLimit{}},
+		{"stock=10", map[EndpointGroup]RateLimit{GroupStock: {Rate: 10}}},
+		{"stock=10/5/4, order=0.5", map[EndpointGroup]RateLimit{
+			GroupStock: {Rate: 10, Burst: 5, MaxInFlight: 4},
+			GroupOrder: {Rate: 0.5},
+		}},
+		{"price=0/0/2", map[EndpointGroup]RateLimit{GroupPrice: {MaxInFlight: 2}}},
+		{"cart=1,cart=2/3", map[EndpointGroup]RateLimit{GroupCart: {Rate: 2, Burst: 3}}},
+		{" other = 3/1 ", map[EndpointGroup]RateLimit{GroupOther: {Rate: 3, Burst: 1}}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRateLimits(tt.s)
+		if err != nil {
+			t.Errorf("ParseRateLimits(%q): %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRateLimits(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseRateLimitsErrors(t *testing.T) {
+	for _, s := range []string{
+		"stock",
+		"stock=",
+		"=10",
+		"login=10",
+		"STOCK=10",
+		"stock=x",
+		"stock=-1",
+		"stock=10/x",
+		"stock=10/-1",
+		"stock=10/1.5",
+		"stock=10/5/x",
+		"stock=10/5/-4",
+		"stock=10/5/4/3",
+		"stock=10,order",
+	} {
+		if got, err := ParseRateLimits(s); err == nil {
+			t.Errorf("ParseRateLimits(%q) = %+v, want error", s, got)
+		}
+	}
+}
@@ -0,0 +1,683 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RushItem is a goods to rush buy
+//
+type RushItem struct {
+	ID       string
+	Count    int     // buying count
+	MaxPrice float64 // do not buy if the price is higher, 0 means no limit
+	Priority int     // higher priority goods get the Workers slots first
+
+	// Alternatives are bought instead of the goods if it is not in stock
+	// within FallbackAfter, the former ones are preferred. The stock of the
+	// goods and alternatives are polled together, only one of them is bought.
+	// FallbackAfter 0 accepts the alternatives at once, the first one in
+	// stock is bought if the goods is not.
+	Alternatives  []RushItem
+	FallbackAfter time.Duration
+}
+
+// OrderPolicy decide whether to submit the order by the goods in cart
+//
+//   any    at least one goods is in cart, it is the default
+//   all    every goods is in cart at the requested count
+//   min-N  at least N goods are in cart, e.g. min-2
+//
+type OrderPolicy string
+
+const (
+	OrderAny OrderPolicy = "any"
+	OrderAll OrderPolicy = "all"
+)
+
+// ParseOrderPolicy return the policy by name, empty for OrderAny
+//
+func ParseOrderPolicy(s string) (OrderPolicy, error) {
+	p := OrderPolicy(s)
+	switch {
+	case s == "":
+		return OrderAny, nil
+	case p == OrderAny, p == OrderAll:
+		return p, nil
+	case strings.HasPrefix(s, "min-"):
+		if n, err := strconv.Atoi(s[4:]); err == nil && n > 0 {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown order policy %q, want any, all or min-N", s)
+}
+
+// check return nil if the goods in cart satisfy the policy
+//
+func (p OrderPolicy) check(items []*RushResult) error {
+	var inCart, filled int
+	for _, r := range items {
+		if r.InCart {
+			inCart++
+			if r.Count == r.Requested {
+				filled++
+			}
+		}
+	}
+
+	var ok bool
+	switch {
+	case p == OrderAll:
+		ok = filled == len(items)
+	case strings.HasPrefix(string(p), "min-"):
+		n, _ := strconv.Atoi(string(p[4:]))
+		ok = inCart >= n
+	default:
+		ok = inCart > 0
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: %s, %d of %d goods in cart, %d at the requested count",
+			ErrOrderPolicy, p, inCart, len(items), filled)
+	}
+	return nil
+}
+
+// RushResult is the result of rushing a goods
+//
+type RushResult struct {
+	ID        string `json:"id"`              // the goods bought, may be an alternative
+	Group     string `json:"group,omitempty"` // the goods with alternatives
+	Name      string `json:"name,omitempty"`
+	Requested int    `json:"requested"`
+	Count     int    `json:"count"`    // count in cart
+	InCart    bool   `json:"in_cart"`  // added to cart and selected
+	OrderID   string `json:"order_id"` // the order submitted with the goods
+	Err       error  `json:"-"`
+
+	Polls     int           `json:"polls"` // count of stock polls
+	Detail    time.Duration `json:"-"`     // time of sku detail lookup
+	StockWait time.Duration `json:"-"`     // time until in stock
+	AddToCart time.Duration `json:"-"`     // latency of adding to cart
+}
+
+// MarshalJSON encode Err as string and the durations as milliseconds
+//
+func (r RushResult) MarshalJSON() ([]byte, error) {
+	type result RushResult
+	v := struct {
+		result
+		Error       string  `json:"error,omitempty"`
+		DetailMS    float64 `json:"detail_ms"`
+		StockWaitMS float64 `json:"stock_wait_ms"`
+		AddToCartMS float64 `json:"add_to_cart_ms"`
+	}{
+		result:      result(r),
+		DetailMS:    millis(r.Detail),
+		StockWaitMS: millis(r.StockWait),
+		AddToCartMS: millis(r.AddToCart),
+	}
+	if r.Err != nil {
+		v.Error = r.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// OrderResult is the result of previewing and submitting the order
+//
+type OrderResult struct {
+	Preview   *OrderPreview `json:"preview,omitempty"`
+	Submitted bool          `json:"submitted"`
+	OrderID   string        `json:"order_id"`
+	Err       error         `json:"-"`
+
+	PreviewTime time.Duration `json:"-"` // latency of order preview
+	SubmitTime  time.Duration `json:"-"` // latency of order submit
+}
+
+// MarshalJSON encode Err as string and the durations as milliseconds
+//
+func (r OrderResult) MarshalJSON() ([]byte, error) {
+	type result OrderResult
+	v := struct {
+		result
+		Error         string  `json:"error,omitempty"`
+		PreviewTimeMS float64 `json:"preview_ms"`
+		SubmitTimeMS  float64 `json:"submit_ms"`
+	}{
+		result:        result(r),
+		PreviewTimeMS: millis(r.PreviewTime),
+		SubmitTimeMS:  millis(r.SubmitTime),
+	}
+	if r.Err != nil {
+		v.Error = r.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// RushReport is returned by RushBuy for post-mortems after a sale
+//
+type RushReport struct {
+	Profile string        `json:"profile,omitempty"`
+	Start   time.Time     `json:"start"`
+	Elapsed time.Duration `json:"-"`
+	Items   []*RushResult `json:"items"` // in the same order as the goods
+	Order   *OrderResult  `json:"order,omitempty"`
+
+	// Rates is the metrics of rate limits when rush finished, shared by
+	// all the profiles of the process
+	Rates []RateStat `json:"rate_limits,omitempty"`
+}
+
+// MarshalJSON encode Elapsed as milliseconds
+//
+func (r RushReport) MarshalJSON() ([]byte, error) {
+	type report RushReport
+	return json.Marshal(struct {
+		report
+		ElapsedMS float64 `json:"elapsed_ms"`
+	}{
+		report:    report(r),
+		ElapsedMS: millis(r.Elapsed),
+	})
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// RushError aggregates the errors of goods and the order in RushBuy,
+// errors.Is / errors.As match any of them.
+//
+type RushError struct {
+	Errors []error
+}
+
+func (e *RushError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is report whether any of the errors matches target
+//
+func (e *RushError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap return the errors, as the error of errors.Join
+//
+func (e *RushError) Unwrap() []error {
+	return e.Errors
+}
+
+// As find the first error matches target
+//
+func (e *RushError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// RushBuy 支持多件商品抢购, 所有商品加入购物车后统一下单
+//
+// The stock of each goods is polled on its own, Workers caps the goods
+// sending requests at the same time and hands the slots to the higher
+// priority goods first. After all of them are done, the goods in cart are
+// submitted in one order if AutoSubmit is set and the OrderPolicy is
+// satisfied. Rushing more than one goods with AutoRush needs the Deadline
+// or PolicyDeadline, otherwise the goods in cart may wait for the others
+// forever. The goods not in stock at PolicyDeadline are given up, the
+// OrderPolicy is checked with the goods in cart, which are unselected if
+// the order is rejected. The report is always returned, the error is a
+// *RushError of all the failures, nil if all succeeded, or the error of
+// selecting the address and cart before rush.
+//
+func (jd *JingDong) RushBuy(items []RushItem) (*RushReport, error) {
+	report := &RushReport{
+		Profile: jd.Profile,
+		Start:   time.Now(),
+		Items:   make([]*RushResult, len(items)),
+	}
+	defer func() {
+		report.Elapsed = time.Since(report.Start)
+		report.Rates = RateStats()
+	}()
+
+	for i, item := range items {
+		report.Items[i] = &RushResult{ID: item.ID, Requested: item.Count}
+	}
+
+	if jd.AutoRush && jd.Deadline.IsZero() && jd.PolicyDeadline.IsZero() && len(items) > 1 {
+		return report, fmt.Errorf("rush of %d goods needs the deadline", len(items))
+	}
+
+	if jd.Address != "" {
+		if _, err := jd.SelectAddress(jd.Address); err != nil {
+			return report, err
+		}
+	}
+
+	// 只下单本次抢购的商品, 购物车中其他已勾选的商品取消勾选
+	if err := jd.SelectOnly(); err != nil {
+		return report, err
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return items[order[i]].Priority > items[order[j]].Priority
+	})
+
+	var (
+		wg  sync.WaitGroup
+		run = rushRun{deadline: jd.Deadline}
+	)
+	if jd.Workers > 0 && jd.Workers < len(items) {
+		run.sem = newWorkerSem(jd.Workers)
+	}
+	if d := jd.PolicyDeadline; !d.IsZero() && (run.deadline.IsZero() || d.Before(run.deadline)) {
+		run.deadline = d
+	}
+	// start the goods by priority, each with the first slot held, so that
+	// the goods of lower priority do not take the slots before
+	for _, i := range order {
+		r := run
+		r.priority = items[i].Priority
+		r.held = r.sem.acquire(r.priority)
+
+		wg.Add(1)
+		go func(i int, r *rushRun) {
+			defer wg.Done()
+			jd.rushItem(items[i], report.Items[i], r)
+		}(i, &r)
+	}
+	wg.Wait()
+
+	var inCart []*RushResult
+	for _, r := range report.Items {
+		if r.InCart {
+			inCart = append(inCart, r)
+		}
+	}
+	if len(inCart) == 0 {
+		return report, report.err()
+	}
+
+	if err := jd.OrderPolicy.check(report.Items); err != nil {
+		jd.Logger.Warn(jd.msg(msgOrderPolicy), err)
+		report.Order = &OrderResult{Err: err}
+
+		// do not leave the goods selected for the next order
+		ids := make([]string, len(inCart))
+		for i, r := range inCart {
+			ids[i] = r.ID
+		}
+		if err = jd.UnselectItems(ids...); err == nil {
+			for _, r := range inCart {
+				r.InCart = false
+			}
+		}
+		return report, report.err()
+	}
+
+	report.Order = jd.submitRush()
+	for _, r := range inCart {
+		r.OrderID = report.Order.OrderID
+	}
+	return report, report.err()
+}
+
+// rushRun is shared by the goods of a RushBuy, each goods has a copy
+// with its priority
+//
+type rushRun struct {
+	sem      *workerSem
+	deadline time.Time // stop polling the stock, zero means no deadline
+	priority int
+	held     func() // release the slot acquired before the goods started
+}
+
+// acquire wait for a slot of the goods, it must be released by the
+// returned func
+//
+func (run *rushRun) acquire() func() {
+	if release := run.held; release != nil {
+		run.held = nil
+		return release
+	}
+	return run.sem.acquire(run.priority)
+}
+
+// workerSem cap the goods sending requests at the same time, nil means
+// no cap. The slot is not held while waiting for the next poll, a free
+// slot is handed to the waiting goods of the highest priority, the
+// earliest one if the same.
+//
+type workerSem struct {
+	mu      sync.Mutex
+	free    int
+	waiters []*semWaiter // in the order of waiting
+}
+
+type semWaiter struct {
+	priority int
+	ready    chan struct{}
+}
+
+func newWorkerSem(n int) *workerSem {
+	return &workerSem{free: n}
+}
+
+// acquire wait for a slot, it must be released by the returned func
+//
+func (s *workerSem) acquire(priority int) func() {
+	if s == nil {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiters) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return s.release
+	}
+	w := &semWaiter{priority: priority, ready: make(chan struct{})}
+	s.waiters = append(s.waiters, w)
+	s.mu.Unlock()
+
+	<-w.ready
+	return s.release
+}
+
+// release hand the slot to the waiter of the highest priority
+//
+func (s *workerSem) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.waiters) == 0 {
+		s.free++
+		return
+	}
+	next := 0
+	for i, w := range s.waiters {
+		if w.priority > s.waiters[next].priority {
+			next = i
+		}
+	}
+	close(s.waiters[next].ready)
+	s.waiters = append(s.waiters[:next], s.waiters[next+1:]...)
+}
+
+// rushItem buy the goods and fill the result
+//
+func (jd *JingDong) rushItem(item RushItem, r *RushResult, run *rushRun) {
+	if len(item.Alternatives) > 0 {
+		jd.rushGroup(item, r, run)
+		return
+	}
+
+	release := run.acquire()
+	start := time.Now()
+	sku, err := jd.SKUDetail(item.ID)
+	r.Detail = time.Since(start)
+	release()
+	if err != nil {
+		r.Err = itemError(item.ID, err)
+		return
+	}
+
+	r.Name = sku.Name
+	sku.Count = item.Count
+	sku.MaxPrice = item.MaxPrice
+
+	start = time.Now()
+	r.Polls, err = jd.waitStock(sku, run)
+	r.StockWait = time.Since(start)
+	if err != nil {
+		r.Err = itemError(item.ID, err)
+		return
+	}
+
+	release = run.acquire()
+	defer release()
+	if err = jd.checkGuards(sku); err != nil {
+		r.Err = itemError(sku.ID, err)
+		return
+	}
+	jd.rushSKU(sku, r)
+}
+
+// rushGroup buy the first goods in stock of the group, the alternatives
+// are accepted after FallbackAfter. The goods failed by the purchase limit
+// or the max price are dropped, and the others of the group are tried.
+//
+func (jd *JingDong) rushGroup(item RushItem, r *RushResult, run *rushRun) {
+	r.Group = item.ID
+	members := append([]RushItem{item}, item.Alternatives...)
+
+	release := run.acquire()
+	start := time.Now()
+	// skus[0] is the goods, nil for the goods can not be found
+	var (
+		skus  = make([]*SKUInfo, len(members))
+		found int
+	)
+	for i, m := range members {
+		sku, err := jd.SKUDetail(m.ID)
+		if err != nil {
+			// skip the goods can not be found, unless all of them
+			r.Err = itemError(m.ID, err)
+			continue
+		}
+		sku.Count = m.Count
+		sku.MaxPrice = m.MaxPrice
+		skus[i] = sku
+		found++
+	}
+	r.Detail = time.Since(start)
+	release()
+	if found == 0 {
+		return
+	}
+	r.Err = nil
+	jd.buyGroup(item, skus, r, run)
+}
+
+// buyGroup wait for the goods of group in stock and buy it, skus are the
+// members of group, the nil ones are skipped
+//
+func (jd *JingDong) buyGroup(item RushItem, skus []*SKUInfo, r *RushResult, run *rushRun) {
+	start := time.Now()
+	for {
+		sku, polls, err := jd.waitGroup(item.ID, skus, item.FallbackAfter, start, run)
+		r.Polls, r.StockWait = r.Polls+polls, time.Since(start)
+		if err != nil {
+			r.Err = itemError(item.ID, err)
+			return
+		}
+
+		release := run.acquire()
+		err = jd.checkGuards(sku)
+		if err == nil {
+			if sku.ID != item.ID {
+				jd.Logger.Warn(jd.msg(msgFallback), item.ID, sku.ID)
+			}
+			r.ID, r.Name, r.Requested = sku.ID, sku.Name, sku.Count
+			jd.rushSKU(sku, r)
+		}
+		release()
+		if err == nil {
+			return
+		}
+
+		// try the others in stock
+		r.Err = itemError(sku.ID, err)
+		left := 0
+		for i := range skus {
+			if skus[i] == sku {
+				skus[i] = nil
+			}
+			if skus[i] != nil {
+				left++
+			}
+		}
+		if left == 0 {
+			return
+		}
+		jd.Logger.Warn(jd.msg(msgGroupSkip), sku.ID, err, item.ID)
+	}
+}
+
+// waitGroup poll the stock of the group until one of them can be bought,
+// return the goods and the count of polls. skus[0] is the goods of group,
+// the nil ones are skipped. The alternatives are accepted fallback after
+// start.
+//
+func (jd *JingDong) waitGroup(group string, skus []*SKUInfo, fallback time.Duration, start time.Time, run *rushRun) (*SKUInfo, int, error) {
+	var (
+		ids   []string
+		first *SKUInfo // to log the state when out of stock
+	)
+	for _, sku := range skus {
+		if sku != nil {
+			ids = append(ids, sku.ID)
+			if first == nil {
+				first = sku
+			}
+		}
+	}
+
+	p := &poller{jd: jd, ID: group, deadline: run.deadline}
+	for {
+		// without rush, the alternatives are accepted at once
+		fallen := time.Since(start) >= fallback || !jd.AutoRush
+		for i, sku := range skus {
+			// 34 : out of stock
+			if sku != nil && sku.State != "34" && (i == 0 || fallen) {
+				return sku, p.poll, nil
+			}
+		}
+
+		if !jd.AutoRush {
+			jd.Logger.Warn("%s : %s", first.StateName, first.Name)
+			return nil, p.poll, &ItemError{ID: group, Err: ErrOutOfStock}
+		}
+		if err := p.wait(); err != nil {
+			return nil, p.poll, err
+		}
+
+		release := run.acquire()
+		pollStart := time.Now()
+		stocks, err := jd.StockStates(ids...)
+		release()
+		for _, sku := range skus {
+			if sku == nil {
+				continue
+			}
+			if err == nil {
+				sku.State, sku.StateName = stocks[sku.ID].State, stocks[sku.ID].StateName
+			}
+			jd.emit(PhaseStockPoll, sku.ID, pollStart, err, map[string]interface{}{
+				"poll":       p.poll,
+				"group":      group,
+				"state":      sku.State,
+				"state_name": sku.StateName,
+			})
+		}
+		if err = p.done(err); err != nil {
+			return nil, p.poll, err
+		}
+	}
+}
+
+// checkGuards apply the purchase limit and the max price to the goods in
+// stock before adding it to cart
+//
+func (jd *JingDong) checkGuards(sku *SKUInfo) error {
+	if err := jd.checkLimit(sku); err != nil {
+		return err
+	}
+	return jd.checkPrice(sku)
+}
+
+// rushSKU add the goods in stock to cart and fill the result, the guards
+// are checked before
+//
+func (jd *JingDong) rushSKU(sku *SKUInfo, r *RushResult) {
+	start := time.Now()
+	err := jd.addToCart(sku)
+	r.AddToCart = time.Since(start)
+	if err == nil {
+		jd.cartParams(sku)
+		err = jd.itemAction(URLSelectItem, []*CartItem{sku.cartItem()})
+	}
+	if err != nil {
+		r.Err = itemError(sku.ID, err)
+		return
+	}
+
+	r.Count = sku.Count
+	r.InCart = true
+}
+
+// submitRush preview the order of the goods in cart, and submit it if
+// AutoSubmit is set and the payment is not higher than MaxPayment
+//
+func (jd *JingDong) submitRush() *OrderResult {
+	r := &OrderResult{}
+
+	start := time.Now()
+	r.Preview, r.Err = jd.OrderInfo()
+	r.PreviewTime = time.Since(start)
+	if r.Err != nil || !jd.AutoSubmit {
+		return r
+	}
+	if r.Err = jd.checkPayment(r.Preview); r.Err != nil {
+		return r
+	}
+
+	start = time.Now()
+	r.OrderID, r.Err = jd.SubmitOrder()
+	r.SubmitTime = time.Since(start)
+	r.Submitted = r.Err == nil
+	return r
+}
+
+// err aggregate the errors of goods and order, nil if none
+//
+func (r *RushReport) err() error {
+	var errs []error
+	for _, item := range r.Items {
+		if item.Err != nil {
+			errs = append(errs, item.Err)
+		}
+	}
+	if r.Order != nil && r.Order.Err != nil {
+		errs = append(errs, fmt.Errorf("order: %w", r.Order.Err))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &RushError{Errors: errs}
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseOrderPolicy(t *testing.T) {
+	tests := []struct {
+		s    string
+		want OrderPolicy
+		ok   bool
+	}{
+		{"", OrderAny, true},
+		{"any", OrderAny, true},
+		{"all", OrderAll, true},
+		{"min-1", "min-1", true},
+		{"min-12", "min-12", true},
+		{"min-0", "", false},
+		{"min--1", "", false},
+		{"min-", "", false},
+		{"min-x", "", false},
+		{"min", "", false},
+		{"ALL", "", false},
+		{"none", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOrderPolicy(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseOrderPolicy(%q) = %q, %v, want %q, ok %v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestOrderPolicyCheck(t *testing.T) {
+	items := []*RushResult{
+		{ID: "1", Requested: 2, Count: 2, InCart: true},
+		{ID: "2", Requested: 2, Count: 1, InCart: true}, // clamped
+		{ID: "3", Requested: 1},
+	}
+
+	tests := []struct {
+		p  OrderPolicy
+		ok bool
+	}{
+		{OrderAny, true},
+		{OrderAll, false},
+		{"min-1", true},
+		{"min-2", true},
+		{"min-3", false},
+	}
+	for _, tt := range tests {
+		err := tt.p.check(items)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s check: %v, want ok %v", tt.p, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrOrderPolicy) {
+			t.Errorf("%s check: %v, want ErrOrderPolicy", tt.p, err)
+		}
+	}
+
+	if err := OrderAny.check(nil); err == nil {
+		t.Error("any check of no goods succeeded, want error")
+	}
+	all := []*RushResult{{ID: "1", Requested: 1, Count: 1, InCart: true}}
+	if err := OrderAll.check(all); err != nil {
+		t.Errorf("all check: %v", err)
+	}
+}
+
+func TestRushReportErr(t *testing.T) {
+	r := &RushReport{Items: []*RushResult{
+		{ID: "1"},
+		{ID: "2", Err: itemError("2", ErrOutOfStock)},
+		{ID: "3", Err: itemError("3", &ItemError{ID: "4", Err: ErrDeadline})}, // alternative
+	}}
+	if err := (&RushReport{Items: r.Items[:1]}).err(); err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+
+	err := r.err()
+	if err == nil {
+		t.Fatal("err = nil, want RushError")
+	}
+	if want := "2: out of stock; 4: deadline exceeded"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+	if !errors.Is(err, ErrOutOfStock) || !errors.Is(err, ErrDeadline) {
+		t.Errorf("err = %v, want ErrOutOfStock and ErrDeadline", err)
+	}
+
+	var ie *ItemError
+	if !errors.As(err, &ie) || ie.ID != "2" {
+		t.Errorf("errors.As ItemError = %+v, want the first goods 2", ie)
+	}
+}
+
+func TestWorkerSemPriority(t *testing.T) {
+	s := newWorkerSem(1)
+	release := s.acquire(0)
+
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i, priority := range []int{1, 3, 2, 3} {
+		wg.Add(1)
+		go func(i, priority int) {
+			defer wg.Done()
+			release := s.acquire(priority)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			release()
+		}(i, priority)
+
+		// queue the waiters in order
+		for waiting := 0; waiting != i+1; {
+			time.Sleep(time.Millisecond)
+			s.mu.Lock()
+			waiting = len(s.waiters)
+			s.mu.Unlock()
+		}
+	}
+	release()
+	wg.Wait()
+
+	if want := []int{1, 3, 2, 0}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if s.free != 1 {
+		t.Errorf("free = %d, want 1", s.free)
+	}
+}
+
+func TestRushRunHeld(t *testing.T) {
+	s := newWorkerSem(1)
+	run := &rushRun{sem: s}
+	run.held = s.acquire(run.priority)
+
+	// the held slot is used once
+	run.acquire()()
+	if run.held != nil || s.free != 1 {
+		t.Fatalf("held = %v, free = %d, want nil and 1", run.held != nil, s.free)
+	}
+	run.acquire()()
+	if s.free != 1 {
+		t.Errorf("free = %d, want 1", s.free)
+	}
+
+	// no cap
+	(&rushRun{}).acquire()()
+}
+
+func TestBuyGroupGuards(t *testing.T) {
+	errOffline := errors.New("offline")
+	jd := NewJingDong(JDConfig{
+		MaxRetries: -1,
+		Middlewares: []Middleware{func(http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errOffline
+			})
+		}},
+	})
+
+	item := RushItem{ID: "1", Alternatives: []RushItem{{ID: "2"}, {ID: "3"}, {ID: "4"}}}
+	newSKUs := func() []*SKUInfo {
+		return []*SKUInfo{
+			{ID: "1", State: "33", Count: 2, Limit: 1}, // over the limit
+			{ID: "2", State: "34", Count: 1},           // out of stock
+			{ID: "3", State: "33", Count: 3, Limit: 2}, // over the limit
+			{ID: "4", State: "33", Count: 1},
+		}
+	}
+
+	// the members in stock are tried until one passes the guards
+	r := &RushResult{ID: "1"}
+	jd.buyGroup(item, newSKUs(), r, &rushRun{})
+	if r.ID != "4" || r.InCart {
+		t.Errorf("result = %+v, want goods 4 not in cart", r)
+	}
+	var ie *ItemError
+	if !errors.As(r.Err, &ie) || ie.ID != "4" || !errors.Is(r.Err, errOffline) {
+		t.Errorf("err = %v, want the add to cart error of 4", r.Err)
+	}
+
+	// all the members in stock fail the guards
+	skus := newSKUs()
+	skus[3] = nil
+	r = &RushResult{ID: "1"}
+	jd.buyGroup(item, skus, r, &rushRun{})
+	if !errors.Is(r.Err, ErrOutOfStock) {
+		t.Errorf("err = %v, want ErrOutOfStock of the rest", r.Err)
+	}
+	if skus[0] != nil || skus[2] != nil || skus[1] == nil {
+		t.Errorf("skus = %v, want the goods over the limit dropped", skus)
+	}
+
+	skus = newSKUs()[:1]
+	r = &RushResult{ID: "1"}
+	jd.buyGroup(RushItem{ID: "1"}, skus, r, &rushRun{})
+	if !errors.Is(r.Err, ErrPurchaseLimit) {
+		t.Errorf("err = %v, want ErrPurchaseLimit", r.Err)
+	}
+}
+
+func TestRushReportProfile(t *testing.T) {
+	var events []Event
+	jd := NewJingDong(JDConfig{
+		Profile:  "alice",
+		AutoRush: true,
+		OnEvent:  func(ev Event) { events = append(events, ev) },
+	})
+
+	// fails for no deadline before any request
+	report, _ := jd.RushBuy([]RushItem{{ID: "1"}, {ID: "2"}})
+	if report.Profile != "alice" {
+		t.Errorf("report profile = %q, want alice", report.Profile)
+	}
+
+	jd.emit(PhaseStockPoll, "1", time.Now(), nil, nil)
+	if len(events) == 0 || events[len(events)-1].Profile != "alice" {
+		t.Errorf("events = %+v, want the profile alice", events)
+	}
+}
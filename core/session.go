@@ -0,0 +1,276 @@
+package core
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// URLKeepAlive is a lightweight page which requires login, requesting it
+// periodically keeps the session active and refreshes the cookies.
+//
+const URLKeepAlive = "https://passport.jd.com/loginservice.aspx?method=Login"
+
+// sessionTTL is the estimated lifetime of a login session, used when JD
+// does not tell the expiry of the auth cookie.
+//
+var sessionTTL = time.Hour * 24
+
+// Session describe the current login session
+//
+type Session struct {
+	User      string    // account pin, from cookie "pin"
+	Nick      string    // nick name, from cookie "unick"
+	LoginTime time.Time // QR login time, or first validation of restored cookies
+	Expires   time.Time // estimated expiry of the session
+}
+
+// Session return the current login session, or ErrNotLoggedIn
+//
+func (jd *JingDong) Session() (*Session, error) {
+	return jd.validateLogin(URLForQR[4])
+}
+
+// validateLogin request an authenticated page without redirect and inspect
+// the response. JD redirects to passport login page if the session expired.
+//
+func (jd *JingDong) validateLogin(URL string) (*Session, error) {
+	var (
+		err  error
+		req  *http.Request
+		resp *http.Response
+	)
+
+	if req, err = http.NewRequest("GET", URL, nil); err != nil {
+		jd.Logger.Info(jd.msg(msgRequestFailed), URL, err)
+		return nil, err
+	}
+
+	// copy the client to disable redirect, which may be used concurrently
+	client := *jd.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// disable redirect
+		return http.ErrUseLastResponse
+	}
+
+	if resp, err = client.Do(req); err != nil {
+		jd.Logger.Info(jd.msg(msgNeedLoginErr), err)
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	data, err := responseData(resp)
+	if err != nil {
+		jd.Logger.Info(jd.msg(msgReadRespFailed), err)
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		location := resp.Header.Get("Location")
+		if isLoginPage(location) {
+			jd.Logger.Info(jd.msg(msgNeedLoginRedirect), location)
+			return nil, ErrNotLoggedIn
+		}
+		jd.Logger.Trace("Redirect: %s", location)
+
+	case resp.StatusCode != http.StatusOK:
+		jd.Logger.Info(jd.msg(msgNeedLoginStatus), resp.Status)
+		return nil, ErrNotLoggedIn
+
+	case isLoginPage(string(data)):
+		// redirected by javascript
+		jd.Logger.Info(jd.msg(msgNeedLogin))
+		return nil, ErrNotLoggedIn
+	}
+
+	ss := jd.session()
+	if ss.User == "" {
+		jd.Logger.Info(jd.msg(msgNeedLoginNoUser))
+		return nil, ErrNotLoggedIn
+	}
+
+	return ss, nil
+}
+
+// session build the Session from cookies
+//
+func (jd *JingDong) session() *Session {
+	ss := &Session{}
+	ss.User, _ = url.QueryUnescape(jd.jar.Get("pin"))
+	ss.Nick, _ = url.QueryUnescape(jd.jar.Get("unick"))
+	if ss.Nick == "" {
+		ss.Nick = ss.User
+	}
+
+	jd.sessMu.Lock()
+	if jd.loginAt.IsZero() && ss.User != "" {
+		jd.loginAt = time.Now()
+	}
+	ss.LoginTime = jd.loginAt
+	jd.sessMu.Unlock()
+
+	ss.Expires = ss.LoginTime.Add(sessionTTL)
+	for _, c := range jd.jar.Cookies(nil) {
+		if c.Name == "thor" && !c.Expires.IsZero() {
+			ss.Expires = c.Expires
+			break
+		}
+	}
+
+	return ss
+}
+
+// isLoginPage check whether the URL or page content points to passport login
+//
+func isLoginPage(s string) bool {
+	for _, p := range []string{
+		"passport.jd.com/new/login",
+		"passport.jd.com/uc/login",
+	} {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionKeeper holds the state of the background session keeper
+//
+type sessionKeeper struct {
+	interval time.Duration
+	valid    int32 // 1 : session valid, 0 : need login
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// KeepSession start a background goroutine which validates the login
+// session every interval. A valid session is refreshed by touching
+// URLKeepAlive and the cookies are persisted, an expired one is reported
+// through the Notifier and the QR login is started again.
+//
+// Calling KeepSession when the keeper is running does nothing, the keeper
+// is stopped by Release.
+//
+func (jd *JingDong) KeepSession(interval time.Duration) {
+	jd.keeperMu.Lock()
+	defer jd.keeperMu.Unlock()
+
+	if jd.keeper != nil || interval <= 0 {
+		return
+	}
+
+	jd.keeper = &sessionKeeper{
+		interval: interval,
+		valid:    1,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	jd.Logger.Info(jd.msg(msgKeeperStarted), interval)
+	go jd.keepSession(jd.keeper)
+}
+
+// stopKeeper stop the background session keeper and wait it exit
+//
+func (jd *JingDong) stopKeeper() {
+	jd.keeperMu.Lock()
+	k := jd.keeper
+	jd.keeper = nil
+	jd.keeperMu.Unlock()
+
+	if k != nil {
+		close(k.stop)
+		<-k.done
+	}
+}
+
+func (jd *JingDong) keepSession(k *sessionKeeper) {
+	defer close(k.done)
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ss, err := jd.validateLogin(URLForQR[4])
+		if err == nil {
+			atomic.StoreInt32(&k.valid, 1)
+			jd.refreshSession()
+
+			// Expires is estimated by sessionTTL, only warn the user while
+			// JD still accepts the session, never scan QR code again here
+			if time.Until(ss.Expires) <= k.interval {
+				jd.Logger.Warn(jd.msg(msgSessionExpiring), ss.Expires.Format("2006-01-02 15:04:05"))
+				jd.notify(jd.msg(msgNotifyExpiring))
+			}
+			continue
+		}
+
+		atomic.StoreInt32(&k.valid, 0)
+		jd.Logger.Warn(jd.msg(msgSessionExpired))
+		jd.notify(jd.msg(msgNotifyExpired))
+
+		jd.loginMu.Lock()
+		err = jd.loginByQR()
+		jd.loginMu.Unlock()
+
+		if err != nil {
+			jd.Logger.Error(jd.msg(msgReloginFailed), err)
+		}
+	}
+}
+
+// notify send message by Notifier
+//
+func (jd *JingDong) notify(msg string) {
+	if err := jd.Notifier.Notify(msg); err != nil {
+		jd.Logger.Error(jd.msg(msgNotifyFailed), err)
+	}
+}
+
+// refreshSession touch the keep alive page and save the refreshed cookies
+//
+func (jd *JingDong) refreshSession() {
+	req, err := http.NewRequest("GET", URLKeepAlive, nil)
+	if err != nil {
+		jd.Logger.Error(jd.msg(msgRequestFailed), URLKeepAlive, err)
+		return
+	}
+
+	req.Header.Set("Referer", "https://www.jd.com/")
+
+	resp, err := jd.client.Do(req)
+	if err != nil {
+		jd.Logger.Warn(jd.msg(msgRefreshFailed), err)
+		return
+	}
+	resp.Body.Close()
+
+	if err = jd.jar.Persist(); err != nil {
+		jd.Logger.Error(jd.msg(msgSaveCookiesFailed), err)
+		return
+	}
+	jd.Logger.Trace(jd.msg(msgSessionRefreshed))
+}
+
+// ensureSession login again before the session is used if the keeper
+// found it expired and the re-login has not finished yet.
+//
+func (jd *JingDong) ensureSession() error {
+	jd.keeperMu.Lock()
+	k := jd.keeper
+	jd.keeperMu.Unlock()
+
+	if k == nil || atomic.LoadInt32(&k.valid) == 1 {
+		return nil
+	}
+	return jd.Login()
+}
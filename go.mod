@@ -3,21 +3,24 @@ module github.com/adyzng/go-jd
 go 1.17
 
 require (
-	github.com/Adyzng/go-jd v0.0.0-20211115090233-68e0acd99522
+	github.com/BurntSushi/toml v1.3.2
 	github.com/PuerkitoBio/goquery v1.8.0
-	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394
+	github.com/andybalholm/brotli v1.1.1
 	github.com/bitly/go-simplejson v0.5.0
+	github.com/fatih/color v1.13.0
+	github.com/mattn/go-colorable v0.1.9
+	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8
+	golang.org/x/text v0.16.0
 	gopkg.in/clog.v1 v1.2.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869 // indirect
-	github.com/fatih/color v1.13.0 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
-	github.com/mattn/go-colorable v0.1.9 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/smartystreets/goconvey v1.7.2 // indirect
-	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8 // indirect
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 )
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/adyzng/go-jd/core"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// jsonEmitter write each core.Event as one JSON line
+//
+type jsonEmitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJSONEmitter(w io.Writer) *jsonEmitter {
+	return &jsonEmitter{enc: json.NewEncoder(w)}
+}
+
+// Emit is used as core.JDConfig.OnEvent
+//
+func (e *jsonEmitter) Emit(ev core.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.enc.Encode(ev)
+}